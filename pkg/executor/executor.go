@@ -0,0 +1,173 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// An Executor runs a single command.
+type Executor interface {
+	// Execute runs the command described by args in the specified directory
+	// and returns its captured output.
+	Execute(ctx context.Context, dir string, args []string) (stdout []byte, stderr []byte, err error)
+}
+
+// OSExecutor runs commands as sub-processes.
+type OSExecutor struct {
+	// Env is the environment of the commands. If nil, the environment of the
+	// current process is used.
+	Env []string
+}
+
+var _ Executor = OSExecutor{}
+
+func (e OSExecutor) Execute(ctx context.Context, dir string, args []string) ([]byte, []byte, error) {
+	if len(args) == 0 {
+		return nil, nil, errors.New("empty command")
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = e.Env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// Options configures how commands are run.
+type Options struct {
+	// Parallelism is the maximum number of commands run concurrently. Commands
+	// are run one at a time if it is lower than 2.
+	//
+	// Commands which depend on each other must not be run in parallel.
+	Parallelism int
+}
+
+// A Result is the outcome of a command.
+type Result struct {
+	Args   []string
+	Stdout []byte
+	Stderr []byte
+	Err    error
+}
+
+// Error is returned by Run when some commands failed.
+type Error struct {
+	Failed []Result
+}
+
+func (e *Error) Error() string {
+	b := &strings.Builder{}
+
+	for i, result := range e.Failed {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "command `%s` failed: %s", strings.Join(result.Args, " "), result.Err)
+		if stderr := strings.TrimSpace(string(result.Stderr)); stderr != "" {
+			fmt.Fprintf(b, "\n%s", stderr)
+		}
+	}
+
+	return b.String()
+}
+
+// Unique returns the commands without duplicates, keeping the first occurrence
+// of each of them.
+func Unique(cmds [][]string) [][]string {
+	seen := map[string]bool{}
+	unique := make([][]string, 0, len(cmds))
+
+	for _, cmd := range cmds {
+		// A NUL byte can't be part of an argument, so the key is unambiguous.
+		key := strings.Join(cmd, "\x00")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, cmd)
+	}
+
+	return unique
+}
+
+// Run executes a list of commands from the specified directory with the
+// specified executor.
+//
+// Identical commands are only executed once. Results are returned in the
+// order of the commands, whatever the parallelism. Once a command fails, no
+// other command is started and an *Error listing the failures is returned.
+func Run(ctx context.Context, e Executor, dir string, cmds [][]string, options Options) ([]Result, error) {
+	cmds = Unique(cmds)
+	results := make([]*Result, len(cmds))
+	parallelism := options.Parallelism
+
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	failed := false
+	slots := make(chan struct{}, parallelism)
+
+	for i, cmd := range cmds {
+		slots <- struct{}{}
+
+		mutex.Lock()
+		stop := failed
+		mutex.Unlock()
+
+		if stop || ctx.Err() != nil {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, cmd []string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			stdout, stderr, err := e.Execute(ctx, dir, cmd)
+			results[i] = &Result{Args: cmd, Stdout: stdout, Stderr: stderr, Err: err}
+
+			if err != nil {
+				mutex.Lock()
+				failed = true
+				mutex.Unlock()
+			}
+		}(i, cmd)
+	}
+	wg.Wait()
+
+	var executed []Result
+	var failures []Result
+
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		executed = append(executed, *result)
+		if result.Err != nil {
+			failures = append(failures, *result)
+		}
+	}
+
+	if len(failures) > 0 {
+		return executed, &Error{Failed: failures}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return executed, err
+	}
+
+	return executed, nil
+}
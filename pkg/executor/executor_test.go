@@ -0,0 +1,110 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnique(t *testing.T) {
+	cmds := [][]string{
+		{"go", "generate", "./a.go"},
+		{"goimports", "-l", "-w", "./a.go"},
+		{"go", "generate", "./a.go"},
+		{"go", "generate ./a.go"},
+	}
+
+	assert.Equal(t, [][]string{
+		{"go", "generate", "./a.go"},
+		{"goimports", "-l", "-w", "./a.go"},
+		{"go", "generate ./a.go"},
+	}, Unique(cmds))
+}
+
+func TestRun(t *testing.T) {
+	e := &FakeExecutor{
+		Handler: func(dir string, args []string) ([]byte, []byte, error) {
+			return []byte(args[0]), nil, nil
+		},
+	}
+
+	results, err := Run(context.Background(), e, "output", [][]string{{"a"}, {"b"}, {"a"}}, Options{})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "a", string(results[0].Stdout))
+	assert.Equal(t, "b", string(results[1].Stdout))
+	assert.Equal(t, []Call{{Dir: "output", Args: []string{"a"}}, {Dir: "output", Args: []string{"b"}}}, e.Calls())
+}
+
+func TestRunFailure(t *testing.T) {
+	e := &FakeExecutor{
+		Handler: func(dir string, args []string) ([]byte, []byte, error) {
+			if args[0] == "b" {
+				return nil, []byte("boom\n"), errors.New("exit status 1")
+			}
+			return nil, nil, nil
+		},
+	}
+
+	results, err := Run(context.Background(), e, ".", [][]string{{"a"}, {"b"}, {"c"}}, Options{})
+
+	var execErr *Error
+	require.True(t, errors.As(err, &execErr))
+	assert.Equal(t, "command `b` failed: exit status 1\nboom", err.Error())
+	assert.Len(t, results, 2)
+	assert.Len(t, e.Calls(), 2)
+}
+
+func TestRunParallel(t *testing.T) {
+	var running, maxRunning int32
+	e := &FakeExecutor{
+		Handler: func(dir string, args []string) ([]byte, []byte, error) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return []byte(args[0]), nil, nil
+		},
+	}
+
+	cmds := [][]string{{"a"}, {"b"}, {"c"}, {"d"}, {"e"}, {"f"}}
+	results, err := Run(context.Background(), e, ".", cmds, Options{Parallelism: 3})
+	require.NoError(t, err)
+	require.Len(t, results, len(cmds))
+	for i, result := range results {
+		assert.Equal(t, cmds[i], result.Args)
+		assert.Equal(t, cmds[i][0], string(result.Stdout))
+	}
+	assert.LessOrEqual(t, maxRunning, int32(3))
+	assert.Greater(t, maxRunning, int32(1))
+}
+
+func TestRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	e := &FakeExecutor{}
+	results, err := Run(ctx, e, ".", [][]string{{"a"}}, Options{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, results)
+	assert.Empty(t, e.Calls())
+}
+
+func TestOSExecutor(t *testing.T) {
+	stdout, _, err := OSExecutor{}.Execute(context.Background(), t.TempDir(), []string{"go", "env", "GOOS"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, stdout)
+
+	_, _, err = OSExecutor{}.Execute(context.Background(), ".", nil)
+	assert.Error(t, err)
+}
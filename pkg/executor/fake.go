@@ -0,0 +1,43 @@
+package executor
+
+import (
+	"context"
+	"sync"
+)
+
+// A Call is a command received by a FakeExecutor.
+type Call struct {
+	Dir  string
+	Args []string
+}
+
+// FakeExecutor records the commands it receives instead of running them.
+type FakeExecutor struct {
+	// Handler, if set, produces the outcome of the commands.
+	Handler func(dir string, args []string) (stdout []byte, stderr []byte, err error)
+
+	mutex sync.Mutex
+	calls []Call
+}
+
+var _ Executor = (*FakeExecutor)(nil)
+
+func (e *FakeExecutor) Execute(ctx context.Context, dir string, args []string) ([]byte, []byte, error) {
+	e.mutex.Lock()
+	e.calls = append(e.calls, Call{Dir: dir, Args: args})
+	e.mutex.Unlock()
+
+	if e.Handler == nil {
+		return nil, nil, nil
+	}
+
+	return e.Handler(dir, args)
+}
+
+// Calls returns the commands received so far, in the order they were received.
+func (e *FakeExecutor) Calls() []Call {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return append([]Call{}, e.calls...)
+}
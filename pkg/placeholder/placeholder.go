@@ -48,6 +48,10 @@ func (m CodeSectionMark) parsePlaceholders(data []byte) (placeholders []Placehol
 // DefaultCodeSectionMarks is the list of default code section marks.
 var DefaultCodeSectionMarks = []CodeSectionMark{"//", "#", "#pragma "}
 
+// CodeSectionMarks is a list of code section marks to look for placeholders
+// with, instead of DefaultCodeSectionMarks.
+type CodeSectionMarks []CodeSectionMark
+
 // Placeholder represents a placeholder in a file.
 type Placeholder struct {
 	Raw        []byte
@@ -76,9 +80,14 @@ func (p Placeholder) WithContent(content []byte) Placeholder {
 
 // FindAll finds all placeholders in data and returns them.
 func FindAll(data []byte) []Placeholder {
+	return CodeSectionMarks(DefaultCodeSectionMarks).FindAll(data)
+}
+
+// FindAll finds all placeholders in data and returns them.
+func (marks CodeSectionMarks) FindAll(data []byte) []Placeholder {
 	placeholders := make([]Placeholder, 0)
 
-	for _, mark := range DefaultCodeSectionMarks {
+	for _, mark := range marks {
 		placeholders = append(placeholders, mark.parsePlaceholders(data)...)
 	}
 
@@ -88,9 +97,15 @@ func FindAll(data []byte) []Placeholder {
 // ReplaceAll replaces all placeholders in the specified input data and
 // produces the specified output data.
 func ReplaceAll(data []byte, placeholders []Placeholder) []byte {
+	return CodeSectionMarks(DefaultCodeSectionMarks).ReplaceAll(data, placeholders)
+}
+
+// ReplaceAll replaces all placeholders in the specified input data and
+// produces the specified output data.
+func (marks CodeSectionMarks) ReplaceAll(data []byte, placeholders []Placeholder) []byte {
 	// Convert old DOS line ending format (CRLF) to linux format (LF).
 	data = crRemover.Bytes(data)
-	targetPlaceholders := FindAll(data)
+	targetPlaceholders := marks.FindAll(data)
 
 	for _, placeholder := range placeholders {
 		for _, targetPlaceholder := range targetPlaceholders {
@@ -108,6 +123,12 @@ func ReplaceAll(data []byte, placeholders []Placeholder) []byte {
 // FindAndReplaceAll finds all placeholders from the specified `src` and
 // replace it in the specified `dest`.
 func FindAndReplaceAll(src []byte, dest []byte) []byte {
-	placeholders := FindAll(src)
-	return ReplaceAll(dest, placeholders)
+	return CodeSectionMarks(DefaultCodeSectionMarks).FindAndReplaceAll(src, dest)
+}
+
+// FindAndReplaceAll finds all placeholders from the specified `src` and
+// replace it in the specified `dest`.
+func (marks CodeSectionMarks) FindAndReplaceAll(src []byte, dest []byte) []byte {
+	placeholders := marks.FindAll(src)
+	return marks.ReplaceAll(dest, placeholders)
 }
@@ -81,3 +81,13 @@ func TestIdentifierParsing(t *testing.T) {
 		})
 	}
 }
+
+func TestCodeSectionMarks(t *testing.T) {
+	data := []byte("-- region CODE_REGION(Foo)\nold\n-- endregion\n// region CODE_REGION(Bar)\nold\n// endregion\n")
+	src := []byte("-- region CODE_REGION(Foo)\nnew\n-- endregion\n// region CODE_REGION(Bar)\nnew\n// endregion\n")
+
+	marks := CodeSectionMarks{"--"}
+	require.Len(t, marks.FindAll(data), 1)
+	require.Empty(t, FindAll([]byte("-- region CODE_REGION(Foo)\nold\n-- endregion\n")))
+	require.Equal(t, "-- region CODE_REGION(Foo)\nnew\n-- endregion\n// region CODE_REGION(Bar)\nold\n// endregion\n", string(marks.FindAndReplaceAll(src, data)))
+}
@@ -0,0 +1,141 @@
+package templating
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// DriftKind describes how a file on disk differs from the generated output.
+type DriftKind string
+
+const (
+	// DriftStale is a file whose content differs from the generated one.
+	DriftStale DriftKind = "stale"
+	// DriftMissing is a generated file which doesn't exist on disk.
+	DriftMissing DriftKind = "missing"
+	// DriftExtraneous is a file which exists on disk but Render would remove,
+	// either because it rendered empty or because it is an orphan.
+	DriftExtraneous DriftKind = "extraneous"
+)
+
+// A DriftedFile is a file which is not up to date with its templates.
+type DriftedFile struct {
+	Kind DriftKind
+	// Template is the path of the template that produces the file.
+	Template string
+	// Path is the path of the file on disk.
+	Path string
+	// Diff is a unified diff from the content on disk to the expected content,
+	// preceded by the old and new modes if the mode differs.
+	Diff string
+}
+
+// DriftError is returned by Check when the files on disk are not up to date.
+type DriftError struct {
+	Files []DriftedFile
+}
+
+func (e *DriftError) Error() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%d generated file(s) are out of date:", len(e.Files))
+
+	for _, file := range e.Files {
+		fmt.Fprintf(b, "\n%s: %s", file.Kind, file.Path)
+	}
+	for _, file := range e.Files {
+		if file.Diff != "" {
+			fmt.Fprintf(b, "\n%s", strings.TrimSuffix(file.Diff, "\n"))
+		}
+	}
+
+	return b.String()
+}
+
+// Check renders a list of templates in memory, including the merge of code
+// regions with the existing files, and compares the result with the content
+// of the specified directory. Nothing is written to disk.
+//
+// Returns a *DriftError if any file differs. Patterns have the same meaning as
+// in Render.
+func Check(templates []Template, root string, ctx interface{}, patterns ...string) error {
+	return CheckFS(NewOSFS(), templates, root, ctx, patterns...)
+}
+
+// CheckFS is like Check but reads the existing files from the specified file system.
+func CheckFS(fsys OutputFS, templates []Template, root string, ctx interface{}, patterns ...string) error {
+	return defaultRenderer(fsys).Check(templates, root, ctx, patterns...)
+}
+
+// Check compares the files on the file system of the renderer with the
+// rendered templates, like the package level Check.
+func (r *Renderer) Check(templates []Template, root string, ctx interface{}, patterns ...string) error {
+	return r.CheckContext(context.Background(), templates, root, ctx, patterns...)
+}
+
+// CheckContext is like Check but aborts with the error of the context once it is done.
+func (r *Renderer) CheckContext(ctx context.Context, templates []Template, root string, data interface{}, patterns ...string) error {
+	actions, err := r.PlanContext(ctx, templates, root, data, patterns...)
+
+	if err != nil {
+		return err
+	}
+
+	driftErr := &DriftError{}
+
+	for _, action := range actions {
+		var kind DriftKind
+
+		switch action.Kind {
+		case ActionCreate:
+			kind = DriftMissing
+		case ActionUpdate:
+			kind = DriftStale
+		case ActionRemove, ActionPrune:
+			if action.Existing == nil {
+				continue
+			}
+			kind = DriftExtraneous
+			action.Content = nil
+		default:
+			continue
+		}
+
+		diff, err := unifiedDiff(action.Path, action.Existing, action.Content)
+
+		if err != nil {
+			return err
+		}
+
+		if kind == DriftStale && action.Mode != 0 && action.Mode != action.existingMode {
+			diff = fmt.Sprintf("old mode %04o\nnew mode %04o\n", action.existingMode, action.Mode) + diff
+		}
+
+		driftErr.Files = append(driftErr.Files, DriftedFile{
+			Kind:     kind,
+			Template: action.Template,
+			Path:     action.Path,
+			Diff:     diff,
+		})
+	}
+
+	if len(driftErr.Files) > 0 {
+		return driftErr
+	}
+
+	return nil
+}
+
+func unifiedDiff(path string, existing []byte, expected []byte) (string, error) {
+	diff := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(existing)),
+		B:        difflib.SplitLines(string(expected)),
+		FromFile: "a/" + path,
+		ToFile:   "b/" + path,
+		Context:  3,
+	}
+
+	return difflib.GetUnifiedDiffString(diff)
+}
@@ -0,0 +1,48 @@
+package templating
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	templates := loadFixturePack(t, "plan")
+	ctx := map[string]interface{}{"Name": "world", "Enabled": false}
+	m := NewMemFS()
+
+	_, _, err := RenderFS(m, templates, "output", ctx)
+	require.NoError(t, err)
+	require.NoError(t, CheckFS(m, templates, "output", ctx))
+
+	require.NoError(t, m.WriteFile(filepath.Join("output", "updated.txt"), []byte("Hello\n// region CODE_REGION(Name)\nnobody\n// endregion\n"), 0666))
+	require.NoError(t, m.Remove(filepath.Join("output", "created.txt")))
+	require.NoError(t, m.WriteFile(filepath.Join("output", "removed.txt"), []byte{}, 0666))
+
+	err = CheckFS(m, templates, "output", ctx)
+
+	var driftErr *DriftError
+	require.True(t, errors.As(err, &driftErr))
+	require.Len(t, driftErr.Files, 3)
+
+	byName := map[string]DriftedFile{}
+	for _, file := range driftErr.Files {
+		byName[filepath.Base(file.Path)] = file
+	}
+
+	assert.Equal(t, DriftMissing, byName["created.txt"].Kind)
+	assert.Contains(t, byName["created.txt"].Diff, "+Hello world\n")
+	assert.Equal(t, DriftStale, byName["updated.txt"].Kind)
+	assert.Equal(t, "updated.txt.template", byName["updated.txt"].Template)
+	assert.Contains(t, byName["updated.txt"].Diff, "-nobody\n+world\n")
+	assert.Equal(t, DriftExtraneous, byName["removed.txt"].Kind)
+	assert.Contains(t, err.Error(), "stale: "+filepath.Join("output", "updated.txt"))
+
+	// Check must not fix anything.
+	data, err := m.ReadFile(filepath.Join("output", "updated.txt"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "nobody")
+}
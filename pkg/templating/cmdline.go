@@ -0,0 +1,83 @@
+package templating
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// splitCommandLine splits a command line into arguments, following the
+// quoting rules of a POSIX shell:
+//
+//   - arguments are separated by unquoted white spaces,
+//   - single quotes preserve the literal value of every character they enclose,
+//   - double quotes preserve the literal value of every character they enclose,
+//     except for a backslash followed by `"`, `\`, `$` or a backtick,
+//   - outside of quotes, a backslash preserves the literal value of the next character.
+//
+// Variables, globs and other shell expansions are not supported.
+func splitCommandLine(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	runes := []rune(line)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case r == '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			current.WriteString(string(runes[i+1 : end]))
+			i = end
+			inArg = true
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+					i++
+				}
+				current.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+
+	return -1
+}
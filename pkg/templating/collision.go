@@ -0,0 +1,133 @@
+package templating
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MergeStrategy describes how the outputs of several templates rendering to
+// the same file are combined, see the `!!merge` header.
+type MergeStrategy string
+
+const (
+	// MergeConcat concatenates the outputs in template order.
+	MergeConcat MergeStrategy = "concat"
+	// MergeRegions uses the output of the first template and replaces its code
+	// regions with the ones of the following templates.
+	MergeRegions MergeStrategy = "regions"
+)
+
+// CollisionError is returned when several templates render to the same file
+// without declaring a common merge strategy. Nothing is written.
+type CollisionError struct {
+	// Path is the path of the output file.
+	Path string
+	// Templates are the paths of the colliding templates, in template order.
+	Templates []string
+}
+
+func (e *CollisionError) Error() string {
+	quoted := make([]string, len(e.Templates))
+
+	for i, template := range e.Templates {
+		quoted[i] = "`" + template + "`"
+	}
+
+	last := len(quoted) - 1
+
+	return fmt.Sprintf("templates %s and %s both render to `%s`", strings.Join(quoted[:last], ", "), quoted[last], e.Path)
+}
+
+// mergeCollisions combines the actions of the templates rendering to the same
+// file, or returns a *CollisionError if they don't all declare the same merge
+// strategy. Templates skipped because of their condition don't produce
+// anything and never collide.
+//
+// The merged action takes the place of the first one and the headers of the
+// first template, such as `!!if-not-exists` and `!!remove-if-empty`, apply to
+// it.
+func (r *Renderer) mergeCollisions(actions []Action) ([]Action, error) {
+	groups := map[string][]int{}
+
+	for i, action := range actions {
+		if action.Kind != ActionSkipCondition {
+			groups[action.Path] = append(groups[action.Path], i)
+		}
+	}
+
+	merged := make([]Action, 0, len(actions))
+
+	for i, action := range actions {
+		group := groups[action.Path]
+
+		if action.Kind == ActionSkipCondition || len(group) < 2 {
+			merged = append(merged, action)
+			continue
+		}
+
+		if group[0] != i {
+			// Merged into the first action of the group.
+			continue
+		}
+
+		colliding := make([]Action, len(group))
+		for j, k := range group {
+			colliding[j] = actions[k]
+		}
+
+		action, err := r.mergeActions(colliding)
+
+		if err != nil {
+			return nil, err
+		}
+
+		merged = append(merged, action)
+	}
+
+	return merged, nil
+}
+
+func (r *Renderer) mergeActions(actions []Action) (Action, error) {
+	strategy := actions[0].merge
+
+	for _, action := range actions {
+		if action.merge == "" || action.merge != strategy {
+			err := &CollisionError{Path: actions[0].Path}
+			for _, action := range actions {
+				err.Templates = append(err.Templates, action.Template)
+			}
+			return Action{}, err
+		}
+	}
+
+	merged := actions[0]
+
+	if merged.Kind == ActionSkipExists {
+		return merged, nil
+	}
+
+	merged.rendered = append([]byte{}, merged.rendered...)
+	merged.commands = append([]func() ([][]string, error){}, merged.commands...)
+
+	for _, action := range actions[1:] {
+		// A template skipped because the file exists doesn't contribute.
+		if action.Kind == ActionSkipExists {
+			continue
+		}
+
+		switch strategy {
+		case MergeConcat:
+			merged.rendered = append(merged.rendered, action.rendered...)
+		case MergeRegions:
+			merged.rendered = r.marks.ReplaceAll(merged.rendered, r.marks.FindAll(action.rendered))
+		}
+
+		merged.commands = append(merged.commands, action.commands...)
+	}
+
+	if err := r.completeAction(&merged); err != nil {
+		return Action{}, err
+	}
+
+	return merged, nil
+}
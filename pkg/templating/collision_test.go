@@ -0,0 +1,75 @@
+package templating
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTemplateString(t *testing.T, path string, source string) Template {
+	t.Helper()
+
+	tmpl, err := LoadTemplate(path, bytes.NewBufferString(source))
+	require.NoError(t, err)
+
+	return tmpl
+}
+
+func TestPlanCollision(t *testing.T) {
+	first := loadTemplateString(t, "first.txt.template", "!!filename same.txt\nfirst\n")
+	second := loadTemplateString(t, "second.txt.template", "!!filename same.txt\n!!merge concat\nsecond\n")
+	skipped := loadTemplateString(t, "skipped.txt.template", "!!filename same.txt\n!!if .Enabled\nskipped\n")
+
+	m := NewMemFS()
+	ctx := map[string]interface{}{"Enabled": false}
+
+	actions, err := PlanFS(m, []Template{first, skipped}, "output", ctx)
+	require.NoError(t, err)
+	assert.Len(t, actions, 2)
+
+	_, _, err = RenderFS(m, []Template{first, skipped, second}, "output", ctx)
+	require.Error(t, err)
+	assert.Equal(t, "templates `first.txt.template` and `second.txt.template` both render to `"+filepath.Join("output", "same.txt")+"`", err.Error())
+
+	var collisionErr *CollisionError
+	require.True(t, errors.As(err, &collisionErr))
+	assert.Equal(t, &CollisionError{
+		Path:      filepath.Join("output", "same.txt"),
+		Templates: []string{"first.txt.template", "second.txt.template"},
+	}, collisionErr)
+	assert.Empty(t, m.Paths())
+}
+
+func TestPlanMergeStrategies(t *testing.T) {
+	m := NewMemFS()
+	require.NoError(t, m.WriteFile(filepath.Join("output", "regions.txt"), []byte("Kept\n// region CODE_REGION(B)\nold\n// endregion\n"), 0666))
+
+	templates := []Template{
+		loadTemplateString(t, "a.txt.template", "!!filename concat.txt\n!!merge concat\n!!generator-command echo a\nA\n"),
+		loadTemplateString(t, "base.txt.template", "!!filename regions.txt\n!!merge regions\nBase\n// region CODE_REGION(A)\n// endregion\n// region CODE_REGION(B)\n// endregion\n"),
+		loadTemplateString(t, "b.txt.template", "!!filename concat.txt\n!!merge concat\n!!generator-command echo b\nB\n"),
+		loadTemplateString(t, "fill.txt.template", "!!filename regions.txt\n!!merge regions\n// region CODE_REGION(B)\n{{ . }}\n// endregion\n"),
+	}
+
+	result, err := RenderFSWithResult(m, templates, "output", "filled")
+	require.NoError(t, err)
+	require.Len(t, result.Files, 2)
+
+	assert.Equal(t, "a.txt.template", result.Files[0].Template)
+	assert.Equal(t, [][]string{{"echo", "a"}, {"echo", "b"}}, result.Files[0].Commands)
+	data, err := m.ReadFile(filepath.Join("output", "concat.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "A\nB\n", string(data))
+
+	assert.Equal(t, []string{"B"}, result.Files[1].Regions)
+	data, err = m.ReadFile(filepath.Join("output", "regions.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "Kept\n// region CODE_REGION(B)\nfilled\n// endregion\n", string(data))
+
+	_, err = LoadTemplate("c.txt.template", bytes.NewBufferString("!!merge append\n"))
+	assert.Error(t, err)
+}
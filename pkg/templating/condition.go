@@ -0,0 +1,112 @@
+package templating
+
+import (
+	"bytes"
+	"io"
+	"text/template"
+	"text/template/parse"
+)
+
+// checkTemplateSyntax parses a template without resolving its functions, so
+// that it can later be executed with the functions of any Renderer.
+func checkTemplateSyntax(source string) error {
+	tree := parse.New("")
+	tree.Mode = parse.SkipFuncCheck
+	_, err := tree.Parse(source, "", "", map[string]*parse.Tree{})
+
+	return err
+}
+
+// evaluateCondition executes a condition template and reports whether it
+// produced an output.
+func evaluateCondition(condition string, ctx interface{}, funcs template.FuncMap) (bool, error) {
+	tmpl, err := template.New("").Funcs(funcs).Parse(condition)
+
+	if err != nil {
+		return false, err
+	}
+
+	buf := &bytes.Buffer{}
+	err = tmpl.Execute(buf, ctx)
+
+	return buf.Len() > 0, err
+}
+
+// evaluateForeach returns the value of the pipeline of a `!!foreach` header.
+func evaluateForeach(pipeline string, ctx interface{}, funcs template.FuncMap) (interface{}, error) {
+	var items interface{}
+
+	capture := template.FuncMap{
+		foreachCaptureFunc: func(value interface{}) string {
+			items = value
+			return ""
+		},
+	}
+
+	tmpl, err := template.New("").Funcs(funcs).Funcs(capture).Parse(foreachSource(pipeline))
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = tmpl.Execute(io.Discard, ctx)
+
+	return items, err
+}
+
+func foreachSource(pipeline string) string {
+	return "{{ " + foreachCaptureFunc + " (" + pipeline + ") }}"
+}
+
+// evaluateIf evaluates the `!!if`, `!!ifor` and `!!when` conditions with the
+// specified functions. Headers which were not parsed fall back to their If,
+// IfOr and When functions.
+func (h Header) evaluateIf(ctx interface{}, funcs template.FuncMap) (ok bool, keyword string, err error) {
+	for _, condition := range []struct {
+		keyword string
+		source  string
+		eval    func(ctx interface{}) (bool, error)
+	}{
+		{"if", h.ifSource, h.If},
+		{"ifor", h.ifOrSource, h.IfOr},
+	} {
+		switch {
+		case condition.source != "":
+			ok, err = evaluateCondition(condition.source, ctx, funcs)
+		case condition.eval != nil:
+			ok, err = condition.eval(ctx)
+		default:
+			continue
+		}
+
+		if err != nil || !ok {
+			return false, condition.keyword, err
+		}
+	}
+
+	switch {
+	case len(h.whenExpressions) > 0:
+		ok, err = evaluateWhenExpressions(h.whenExpressions, ctx, funcs)
+	case h.When != nil:
+		ok, err = h.When(ctx)
+	default:
+		return true, "", nil
+	}
+
+	if err != nil || !ok {
+		return false, "when", err
+	}
+
+	return true, "", nil
+}
+
+// evaluateForeach returns the collection of the `!!foreach` header, evaluated
+// with the specified functions. Headers which were not parsed fall back to
+// their Foreach function.
+func (h Header) evaluateForeach(ctx interface{}, funcs template.FuncMap) (interface{}, error) {
+	if h.foreachSource != "" {
+		return evaluateForeach(h.foreachSource, ctx, funcs)
+	}
+
+	return h.Foreach(ctx)
+}
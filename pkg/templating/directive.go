@@ -0,0 +1,148 @@
+package templating
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// A HeaderDirectiveParser parses the value of a custom header directive into
+// the value stored in Header.Extensions.
+//
+// Previous is the value of the preceding occurrence of the directive in the
+// same header, nil for the first one, so that repeated directives can
+// accumulate their values. The value of a `!!keyword` line without value is
+// empty.
+type HeaderDirectiveParser func(value string, previous interface{}) (interface{}, error)
+
+// A HeaderHook acts on the value of a custom header directive while planning
+// a template, once its conditions were evaluated and before it is rendered.
+// The action describes the output file, its content isn't rendered yet.
+//
+// Returning false skips the template, like a false `!!if` header. Hooks may be
+// called concurrently.
+type HeaderHook func(ctx interface{}, action Action, value interface{}) (bool, error)
+
+type headerDirectiveEntry struct {
+	parse HeaderDirectiveParser
+	hook  HeaderHook
+}
+
+// HeaderDirectiveRegistry holds custom header directives, in addition to the
+// built-in ones such as `!!filename`.
+//
+// A custom directive is available as `!!keyword value` lines and as a key of a
+// front-matter, whose value is either a string or a list of strings parsed one
+// after the other.
+//
+// It is safe for concurrent use.
+type HeaderDirectiveRegistry struct {
+	mutex      sync.RWMutex
+	directives map[string]headerDirectiveEntry
+}
+
+// NewHeaderDirectiveRegistry creates an empty registry.
+func NewHeaderDirectiveRegistry() *HeaderDirectiveRegistry {
+	return &HeaderDirectiveRegistry{directives: map[string]headerDirectiveEntry{}}
+}
+
+// HeaderDirectives is the default registry: the package level functions parse
+// the headers of the templates with it and call its hooks, as do the packs and
+// renderers created without WithPackHeaderDirectives or WithHeaderDirectives.
+var HeaderDirectives = NewHeaderDirectiveRegistry()
+
+var headerKeywordRegexp = regexp.MustCompile(`^[a-z-_]+$`)
+
+// Register adds a custom header directive, replacing any directive previously
+// registered with the same keyword along with its hook.
+//
+// Returns an error if the keyword is invalid or is the one of a built-in
+// directive.
+func (r *HeaderDirectiveRegistry) Register(keyword string, parse HeaderDirectiveParser) error {
+	if !headerKeywordRegexp.MatchString(keyword) {
+		return fmt.Errorf("invalid header directive keyword `%s`", keyword)
+	}
+
+	if _, ok := headerValueKinds[keyword]; ok {
+		return fmt.Errorf("`%s` is a built-in header directive", keyword)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.directives[keyword] = headerDirectiveEntry{parse: parse}
+
+	return nil
+}
+
+// Hook sets the hook acting on the values of a registered directive.
+func (r *HeaderDirectiveRegistry) Hook(keyword string, hook HeaderHook) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	entry, ok := r.directives[keyword]
+
+	if !ok {
+		return fmt.Errorf("unknown header directive `%s`", keyword)
+	}
+
+	entry.hook = hook
+	r.directives[keyword] = entry
+
+	return nil
+}
+
+// Lookup returns the parser of the directive registered with the specified
+// keyword.
+func (r *HeaderDirectiveRegistry) Lookup(keyword string) (HeaderDirectiveParser, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	entry, ok := r.directives[keyword]
+
+	return entry.parse, ok
+}
+
+// runHooks calls the hooks of the custom directives of a header, sorted by
+// keyword. It stops at the first hook skipping the template, and returns its
+// keyword.
+func (r *HeaderDirectiveRegistry) runHooks(ctx interface{}, action Action, header Header) (ok bool, keyword string, err error) {
+	keywords := make([]string, 0, len(header.Extensions))
+
+	for keyword := range header.Extensions {
+		keywords = append(keywords, keyword)
+	}
+
+	sort.Strings(keywords)
+
+	for _, keyword := range keywords {
+		r.mutex.RLock()
+		hook := r.directives[keyword].hook
+		r.mutex.RUnlock()
+
+		if hook == nil {
+			continue
+		}
+
+		if ok, err := hook(ctx, action, header.Extensions[keyword]); err != nil || !ok {
+			return false, keyword, err
+		}
+	}
+
+	return true, "", nil
+}
+
+// valueKind returns how the value of a built-in or custom directive is
+// represented in a front-matter.
+func (r *HeaderDirectiveRegistry) valueKind(keyword string) (headerValueKind, bool) {
+	if kind, ok := headerValueKinds[keyword]; ok {
+		return kind, true
+	}
+
+	if _, ok := r.Lookup(keyword); ok {
+		return headerValueRepeated, true
+	}
+
+	return 0, false
+}
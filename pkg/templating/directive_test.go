@@ -0,0 +1,157 @@
+package templating
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDirectives returns a registry of the `owner` and `requires-feature`
+// directives.
+func newTestDirectives(t *testing.T) *HeaderDirectiveRegistry {
+	t.Helper()
+
+	registry := NewHeaderDirectiveRegistry()
+
+	require.NoError(t, registry.Register("owner", func(value string, _ interface{}) (interface{}, error) {
+		return value, nil
+	}))
+	require.NoError(t, registry.Register("requires-feature", func(value string, previous interface{}) (interface{}, error) {
+		if value == "" {
+			return nil, fmt.Errorf("it requires 1 value")
+		}
+		features, _ := previous.([]string)
+		return append(features, value), nil
+	}))
+
+	return registry
+}
+
+func TestHeaderDirectiveRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry := NewHeaderDirectiveRegistry()
+
+	assert.EqualError(t, registry.Register("filename", nil), "`filename` is a built-in header directive")
+	assert.EqualError(t, registry.Register("Owner", nil), "invalid header directive keyword `Owner`")
+	assert.EqualError(t, registry.Hook("owner", nil), "unknown header directive `owner`")
+
+	require.NoError(t, registry.Register("owner", func(value string, _ interface{}) (interface{}, error) { return value, nil }))
+	require.NoError(t, registry.Hook("owner", func(interface{}, Action, interface{}) (bool, error) { return true, nil }))
+
+	_, ok := registry.Lookup("owner")
+	assert.True(t, ok)
+	_, ok = registry.Lookup("stability")
+	assert.False(t, ok)
+}
+
+func TestParseHeadersCustomDirectives(t *testing.T) {
+	t.Parallel()
+
+	registry := newTestDirectives(t)
+
+	var header Header
+	_, err := registry.ParseHeaders(bytes.NewBufferString("!!owner team-a\n!!requires-feature grpc\n!!requires-feature metrics\nbody\n"), &header)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"owner": "team-a", "requires-feature": []string{"grpc", "metrics"}}, header.Extensions)
+
+	var frontMatterHeader Header
+	_, err = registry.ParseHeaders(bytes.NewBufferString("---\n# codegenerator\nowner: team-a\nrequires-feature: [grpc, metrics]\n---\nbody\n"), &frontMatterHeader)
+	require.NoError(t, err)
+	assert.Equal(t, header.Extensions, frontMatterHeader.Extensions)
+
+	_, err = registry.ParseHeaders(bytes.NewBufferString("!!filename a.txt\n!!requires-feature\n"), &header)
+	assert.EqualError(t, err, "failed to parse `requires-feature` header on line 2: it requires 1 value")
+
+	_, err = registry.ParseHeaders(bytes.NewBufferString("!!stability beta\n"), &header)
+	assert.EqualError(t, err, "unknown template meta-header `stability` on line 1")
+
+	// The default registry is left alone.
+	_, err = ParseHeaders(bytes.NewBufferString("!!owner team-a\n"), &header)
+	assert.EqualError(t, err, "unknown template meta-header `owner` on line 1")
+
+	out := &bytes.Buffer{}
+	require.NoError(t, registry.ConvertHeaders(bytes.NewBufferString("!!owner team-a\n!!requires-feature grpc\n!!requires-feature metrics\nbody\n"), out, HeaderFormatFrontMatter))
+	assert.Equal(t, "---\n# codegenerator\nowner: team-a\nrequires-feature:\n  - grpc\n  - metrics\n---\nbody\n", out.String())
+}
+
+func TestRenderHeaderHooks(t *testing.T) {
+	t.Parallel()
+
+	registry := newTestDirectives(t)
+	templates := []Template{}
+
+	for path, source := range map[string]string{
+		"grpc.txt.template":    "!!owner team-a\n!!requires-feature grpc\ngrpc\n",
+		"metrics.txt.template": "!!requires-feature grpc\n!!requires-feature metrics\nmetrics\n",
+		"plain.txt.template":   "!!owner team-b\nplain\n",
+	} {
+		tmpl, err := registry.LoadTemplate(path, bytes.NewBufferString(source))
+		require.NoError(t, err)
+		templates = append(templates, tmpl)
+	}
+
+	sort.Slice(templates, func(i, j int) bool { return templates[i].GetPath() < templates[j].GetPath() })
+
+	owners := map[string]interface{}{}
+	require.NoError(t, registry.Hook("owner", func(_ interface{}, action Action, value interface{}) (bool, error) {
+		owners[filepath.Base(action.Path)] = value
+		return true, nil
+	}))
+	require.NoError(t, registry.Hook("requires-feature", func(ctx interface{}, _ Action, value interface{}) (bool, error) {
+		for _, feature := range value.([]string) {
+			if !strings.Contains(ctx.(map[string]string)["Features"], feature) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}))
+
+	m := NewMemFS()
+	result, err := NewRenderer(WithOutputFS(m), WithHeaderDirectives(registry)).Render(templates, "output", map[string]string{"Features": "grpc"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{filepath.Join("output", "grpc.txt"), filepath.Join("output", "plain.txt")}, result.Generated())
+	assert.Equal(t, map[string]interface{}{"grpc.txt": "team-a", "plain.txt": "team-b"}, owners)
+
+	// The hooks of other registries aren't called.
+	actions, err := NewRenderer(WithOutputFS(NewMemFS())).Plan(templates, "output", map[string]string{"Features": "metrics"})
+	require.NoError(t, err)
+	require.Len(t, actions, 3)
+	for _, action := range actions {
+		assert.Equal(t, ActionCreate, action.Kind)
+	}
+
+	require.NoError(t, registry.Hook("owner", func(interface{}, Action, interface{}) (bool, error) {
+		return false, fmt.Errorf("no owner")
+	}))
+	_, err = NewRenderer(WithOutputFS(NewMemFS()), WithHeaderDirectives(registry)).Plan(templates, "output", map[string]string{"Features": "grpc"})
+	assert.EqualError(t, err, "running header `owner` hook in `grpc.txt.template`: no owner")
+}
+
+func TestLoadPackCustomDirectives(t *testing.T) {
+	t.Parallel()
+
+	packs := fstest.MapFS{
+		"owned/a.txt.template": {Data: []byte("!!owner team-a\na\n")},
+	}
+
+	pack, err := NewEmbededPackProvider(packs, WithPackHeaderDirectives(newTestDirectives(t))).Provide("", "owned")
+	require.NoError(t, err)
+	templates, err := pack.LoadTemplates()
+	require.NoError(t, err)
+	require.Len(t, templates, 1)
+	assert.Equal(t, map[string]interface{}{"owner": "team-a"}, templates[0].GetHeader().Extensions)
+
+	pack, err = NewEmbededPackProvider(packs).Provide("", "owned")
+	require.NoError(t, err)
+	_, err = pack.LoadTemplates()
+	assert.EqualError(t, err, "loading template from a.txt.template: unknown template meta-header `owner` on line 1")
+}
@@ -1,13 +1,16 @@
 package templating
 
 import (
+	"context"
 	"errors"
 	"io/fs"
 	"path"
+	"text/template"
 )
 
 type embededPackProvider struct {
-	fs fs.ReadDirFS
+	fs      fs.ReadDirFS
+	options packOptions
 }
 
 func (p *embededPackProvider) Provide(templateType, templateName string) (Pack, error) {
@@ -16,6 +19,13 @@ func (p *embededPackProvider) Provide(templateType, templateName string) (Pack,
 	return p.scanPacks(name)
 }
 
+func (p *embededPackProvider) ProvideContext(ctx context.Context, templateType, templateName string) (Pack, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.Provide(templateType, templateName)
+}
+
 func (p *embededPackProvider) scanPacks(name string) (*embededPack, error) {
 	_, err := fs.ReadDir(p.fs, name)
 	if err != nil {
@@ -26,22 +36,25 @@ func (p *embededPackProvider) scanPacks(name string) (*embededPack, error) {
 		return nil, errors.New("failed cd'ing to pack root")
 	}
 	pack := embededPack{
-		name: name,
-		fs:   newRoot.(fs.ReadDirFS),
+		name:    name,
+		fs:      newRoot.(fs.ReadDirFS),
+		options: p.options,
 	}
 	return &pack, nil
 }
-func NewEmbededPackProvider(fs fs.ReadDirFS) *embededPackProvider {
+func NewEmbededPackProvider(fs fs.ReadDirFS, options ...PackOption) *embededPackProvider {
 	return &embededPackProvider{
-		fs: fs,
+		fs:      fs,
+		options: newPackOptions(options),
 	}
 }
 
 var _ Pack = (*embededPack)(nil)
 
 type embededPack struct {
-	name string
-	fs   fs.ReadDirFS
+	name    string
+	fs      fs.ReadDirFS
+	options packOptions
 }
 
 func (p *embededPack) GetName() string {
@@ -51,24 +64,18 @@ func (p *embededPack) GetPath() string {
 	return ""
 }
 
-func (p *embededPack) LoadTemplates() (templates []Template, err error) {
-	err = fs.WalkDir(p.fs, ".", func(path string, d fs.DirEntry, err error) error {
-		if d.IsDir() {
-			return nil
-		}
-		f, err := p.fs.Open(path)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		template, err := LoadTemplate(path, f)
+func (p *embededPack) GetManifest() (*PackManifest, error) {
+	return readPackManifest(p.fs)
+}
 
-		if err != nil {
-			return err
-		}
+func (p *embededPack) getPartials() (*template.Template, error) {
+	return loadPartials(p.fs)
+}
+
+func (p *embededPack) LoadTemplates() (templates []Template, err error) {
+	return p.LoadTemplatesContext(context.Background())
+}
 
-		templates = append(templates, template)
-		return nil
-	})
-	return
+func (p *embededPack) LoadTemplatesContext(ctx context.Context) (templates []Template, err error) {
+	return loadPackTemplates(ctx, p.name, p.fs, p.options)
 }
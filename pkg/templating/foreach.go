@@ -0,0 +1,75 @@
+package templating
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// foreachCaptureFunc is the name of the function capturing the collection of
+// a `!!foreach` header.
+const foreachCaptureFunc = "__foreach"
+
+// ForeachContext is the context a template with a `!!foreach` header is
+// rendered with, once per element of the collection.
+//
+// The `!!filename`, `!!pathreplace`, `!!if`, `!!ifor` and `!!when` headers as
+// well as the generator commands are evaluated with it too.
+type ForeachContext struct {
+	// Item is the current element.
+	Item interface{}
+	// Index is the position of the element, starting at 0.
+	Index int
+	// Key is the key of the element when iterating over a map, nil otherwise.
+	Key interface{}
+	// Root is the context Render was called with.
+	Root interface{}
+}
+
+// foreachContexts returns a context for each element of a slice, an array or
+// a map. Map elements are sorted by key. A nil collection has no element.
+func foreachContexts(items interface{}, root interface{}) ([]ForeachContext, error) {
+	if items == nil {
+		return nil, nil
+	}
+
+	value := reflect.ValueOf(items)
+
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+
+	var contexts []ForeachContext
+
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			contexts = append(contexts, ForeachContext{
+				Item:  value.Index(i).Interface(),
+				Index: i,
+				Root:  root,
+			})
+		}
+	case reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+
+		for i, key := range keys {
+			contexts = append(contexts, ForeachContext{
+				Item:  value.MapIndex(key).Interface(),
+				Index: i,
+				Key:   key.Interface(),
+				Root:  root,
+			})
+		}
+	default:
+		return nil, fmt.Errorf("can't iterate over a value of type %T", items)
+	}
+
+	return contexts, nil
+}
@@ -0,0 +1,69 @@
+package templating
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderForeach(t *testing.T) {
+	templates := loadFixturePack(t, "foreach")
+	m := NewMemFS()
+	ctx := map[string]interface{}{
+		"Service": "Shop",
+		"Resources": []map[string]interface{}{
+			{"Name": "Order Item", "Enabled": true},
+			{"Name": "Draft", "Enabled": false},
+			{"Name": "Customer", "Enabled": true},
+		},
+	}
+
+	result, err := RenderFSWithResult(m, templates, "output", ctx)
+	require.NoError(t, err)
+	require.Len(t, result.Files, 3)
+	assert.Equal(t, StatusSkippedCondition, result.Files[1].Status)
+
+	for path, content := range map[string]string{
+		filepath.Join("output", "order_item", "handler.txt"): "0: Order Item of Shop\n",
+		filepath.Join("output", "customer", "handler.txt"):   "2: Customer of Shop\n",
+	} {
+		data, err := m.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+
+	_, err = m.Stat(filepath.Join("output", "draft", "handler.txt"))
+	assert.Error(t, err)
+
+	// Without resources, the files generated for the previous ones are orphans.
+	result, err = RenderFSWithResult(m, templates, "output", map[string]interface{}{"Service": "Shop"})
+	require.NoError(t, err)
+	require.Len(t, result.Files, 2)
+	for _, file := range result.Files {
+		assert.Equal(t, StatusRemoved, file.Status)
+	}
+
+	_, err = RenderFSWithResult(m, templates, "output", map[string]interface{}{"Resources": 42})
+	assert.EqualError(t, err, "failed to evaluate header `foreach` in `resources/handler.txt.template`: can't iterate over a value of type int")
+}
+
+func TestRenderForeachMap(t *testing.T) {
+	tmpl := loadTemplateString(t, "setting.txt.template", "!!foreach .\n!!filename {{ .Key }}.txt\n{{ .Index }} {{ .Item }}\n")
+	m := NewMemFS()
+
+	_, _, err := RenderFS(m, []Template{tmpl}, "output", map[string]int{"b": 2, "a": 1})
+	require.NoError(t, err)
+
+	data, err := m.ReadFile(filepath.Join("output", "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "0 1\n", string(data))
+	data, err = m.ReadFile(filepath.Join("output", "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "1 2\n", string(data))
+
+	_, err = LoadTemplate("empty.txt.template", bytes.NewBufferString("!!foreach\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,399 @@
+package templating
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatterDelimiter is the line opening and closing the YAML front-matter
+// of a template file, an alternative to `!!keyword value` header lines:
+//
+//	---
+//	# codegenerator
+//	filename: "{{ .Name }}.go"
+//	pathreplace:
+//	  - old: some dir
+//	    new: "{{ .Dir }}"
+//	generator-command:
+//	  - go generate
+//	remove-if-empty: true
+//	---
+//
+// Every header is available under its keyword. Headers which can be repeated
+// take a list, `delimiters` and `postprocess` a list too, and the headers
+// without value a boolean.
+//
+// A template starts with a front-matter when its first line is `---` and its
+// second line is FrontMatterMarker. Any other template, e.g. a YAML document,
+// is left as is.
+const FrontMatterDelimiter = "---"
+
+// FrontMatterMarker is the comment following the opening delimiter of a
+// front-matter, see FrontMatterDelimiter.
+const FrontMatterMarker = "# codegenerator"
+
+// HeaderFormat is the format of the headers of a template file.
+type HeaderFormat string
+
+const (
+	// HeaderFormatLegacy is the `!!keyword value` line format.
+	HeaderFormatLegacy HeaderFormat = "legacy"
+	// HeaderFormatFrontMatter is the YAML front-matter format, see
+	// FrontMatterDelimiter.
+	HeaderFormatFrontMatter HeaderFormat = "front-matter"
+)
+
+// headerValueKind describes the value of a header directive.
+type headerValueKind int
+
+const (
+	// headerValueString is a single string.
+	headerValueString headerValueKind = iota
+	// headerValueFlag is a boolean, a `!!keyword` line without value when true.
+	headerValueFlag
+	// headerValueRepeated is a string or a list of strings, a `!!keyword value`
+	// line each.
+	headerValueRepeated
+	// headerValueArgs is a list of strings, separated by spaces in a
+	// `!!keyword value` line.
+	headerValueArgs
+	// headerValuePathReplace is a list of `old` and `new` pairs, a
+	// `!!pathreplace old new` line each.
+	headerValuePathReplace
+)
+
+var headerValueKinds = map[string]headerValueKind{
+	"filename":          headerValueString,
+	"pathreplace":       headerValuePathReplace,
+	"delimiters":        headerValueArgs,
+	"if":                headerValueString,
+	"ifor":              headerValueString,
+	"when":              headerValueRepeated,
+	"foreach":           headerValueString,
+	"if-not-exists":     headerValueFlag,
+	"generator-command": headerValueRepeated,
+	"remove-if-empty":   headerValueFlag,
+	"no-go-generate":    headerValueFlag,
+	"strict":            headerValueFlag,
+	"postprocess":       headerValueArgs,
+	"merge":             headerValueString,
+	"mode":              headerValueString,
+}
+
+// readFrontMatter reads the front-matter at the beginning of a template file.
+//
+// Directives is nil if the file doesn't start with a front-matter, consumed
+// then holds what was read from the reader.
+func (r *HeaderDirectiveRegistry) readFrontMatter(reader *bufio.Reader) (consumed []byte, directives []headerDirective, err error) {
+	buf := &bytes.Buffer{}
+	line, err := reader.ReadString('\n')
+	buf.WriteString(line)
+
+	if err != nil || strings.TrimSpace(line) != FrontMatterDelimiter {
+		return buf.Bytes(), nil, nil
+	}
+
+	marker, err := reader.ReadString('\n')
+	buf.WriteString(marker)
+
+	if strings.TrimSpace(marker) != FrontMatterMarker {
+		return buf.Bytes(), nil, nil
+	}
+
+	// The block starts with the marker so that its lines are numbered from
+	// the opening delimiter.
+	block := bytes.NewBufferString(marker)
+
+	for {
+		line, err = reader.ReadString('\n')
+
+		if strings.TrimSpace(line) == FrontMatterDelimiter {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("unterminated front-matter: missing closing `%s` line", FrontMatterDelimiter)
+		}
+
+		block.WriteString(line)
+	}
+
+	var document yaml.Node
+
+	if err := yaml.Unmarshal(block.Bytes(), &document); err != nil {
+		return nil, nil, fmt.Errorf("parsing front-matter: %s", err)
+	}
+
+	if len(document.Content) == 0 {
+		return nil, []headerDirective{}, nil
+	}
+
+	mapping := document.Content[0]
+
+	if mapping.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("the front-matter must be a mapping of header keywords")
+	}
+
+	directives, err = r.frontMatterDirectives(mapping)
+
+	return nil, directives, err
+}
+
+// frontMatterDirectives returns the directives of a front-matter mapping.
+// Lines are numbered from the opening delimiter.
+func (r *HeaderDirectiveRegistry) frontMatterDirectives(mapping *yaml.Node) ([]headerDirective, error) {
+	directives := []headerDirective{}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		keyword := key.Value
+		kind, ok := r.valueKind(keyword)
+
+		if !ok {
+			return nil, fmt.Errorf("unknown template meta-header `%s` on line %d", keyword, key.Line+1)
+		}
+
+		directive := headerDirective{keyword: keyword, line: value.Line + 1, column: value.Column}
+
+		if value.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+			directive.column++
+		}
+
+		var err error
+
+		switch kind {
+		case headerValueString:
+			if value.Kind != yaml.ScalarNode {
+				err = fmt.Errorf("expected a string")
+				break
+			}
+			directive.value = value.Value
+			directives = append(directives, directive)
+		case headerValueFlag:
+			var enabled bool
+			if value.Decode(&enabled) != nil {
+				err = fmt.Errorf("expected a boolean")
+			} else if enabled {
+				directives = append(directives, directive)
+			}
+		case headerValueRepeated:
+			items := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				items = value.Content
+			}
+
+			for _, item := range items {
+				directive := directive
+				directive.line, directive.column = item.Line+1, item.Column
+				if item.Kind != yaml.ScalarNode {
+					err = fmt.Errorf("expected a string or a list of strings")
+					break
+				}
+				if item.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+					directive.column++
+				}
+				directive.value = item.Value
+				directives = append(directives, directive)
+			}
+		case headerValueArgs:
+			if value.Kind == yaml.ScalarNode {
+				directive.value = value.Value
+				directive.args = strings.Fields(value.Value)
+			} else if value.Decode(&directive.args) != nil {
+				err = fmt.Errorf("expected a string or a list of strings")
+			} else {
+				directive.value = strings.Join(directive.args, " ")
+			}
+			directives = append(directives, directive)
+		case headerValuePathReplace:
+			var replacements []struct {
+				Old string `yaml:"old"`
+				New string `yaml:"new"`
+			}
+
+			if value.Kind != yaml.SequenceNode || value.Decode(&replacements) != nil {
+				err = fmt.Errorf("expected a list of `old` and `new` pairs")
+				break
+			}
+
+			for j, replacement := range replacements {
+				directive := directive
+				directive.line = value.Content[j].Line + 1
+				directive.value = replacement.Old + " " + replacement.New
+				directive.args = []string{replacement.Old, replacement.New}
+				directives = append(directives, directive)
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `%s` header on line %d: %s", keyword, value.Line+1, err)
+		}
+	}
+
+	return directives, nil
+}
+
+// ConvertHeaders rewrites a template file with its headers in the specified
+// format. The body is copied unmodified.
+//
+// Returns an error if a header can't be represented in the format, e.g. a
+// `pathreplace` header replacing a path containing a space in the legacy
+// format. Custom directives are looked up in HeaderDirectives.
+func ConvertHeaders(r io.Reader, w io.Writer, format HeaderFormat) error {
+	return HeaderDirectives.ConvertHeaders(r, w, format)
+}
+
+// ConvertHeaders is like the package level ConvertHeaders but looks up the
+// custom directives in the registry.
+func (r *HeaderDirectiveRegistry) ConvertHeaders(reader io.Reader, w io.Writer, format HeaderFormat) error {
+	directives, body, err := r.readHeaderDirectives(reader)
+
+	if err != nil {
+		return err
+	}
+
+	var header []byte
+
+	switch format {
+	case HeaderFormatLegacy:
+		header, err = r.formatLegacyHeaders(directives)
+	case HeaderFormatFrontMatter:
+		header, err = r.formatFrontMatter(directives)
+	default:
+		return fmt.Errorf("unknown header format `%s`", format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(header); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, body)
+
+	return err
+}
+
+func (r *HeaderDirectiveRegistry) formatLegacyHeaders(directives []headerDirective) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	for _, directive := range directives {
+		kind, ok := r.valueKind(directive.keyword)
+
+		if !ok {
+			return nil, fmt.Errorf("unknown template meta-header `%s` on line %d", directive.keyword, directive.line)
+		}
+
+		value := directive.value
+
+		switch kind {
+		case headerValueFlag:
+			value = ""
+		case headerValueArgs, headerValuePathReplace:
+			for i, arg := range directive.args {
+				// The last value of `pathreplace` and `delimiters` can contain spaces.
+				last := i == len(directive.args)-1 && kind == headerValuePathReplace || directive.keyword == "delimiters" && i == 1
+
+				if arg == "" || !last && strings.ContainsAny(arg, " \t") {
+					return nil, fmt.Errorf("can't convert `%s` header on line %d: `%s` can't be empty or contain spaces", directive.keyword, directive.line, arg)
+				}
+			}
+			value = strings.Join(directive.args, " ")
+		}
+
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("can't convert `%s` header on line %d: the value spans several lines", directive.keyword, directive.line)
+		}
+
+		buf.WriteString("!!" + directive.keyword)
+		if value != "" {
+			buf.WriteString(" " + value)
+		}
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (r *HeaderDirectiveRegistry) formatFrontMatter(directives []headerDirective) ([]byte, error) {
+	if len(directives) == 0 {
+		return nil, nil
+	}
+
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	values := map[string]*yaml.Node{}
+
+	for _, directive := range directives {
+		kind, ok := r.valueKind(directive.keyword)
+
+		if !ok {
+			return nil, fmt.Errorf("unknown template meta-header `%s` on line %d", directive.keyword, directive.line)
+		}
+
+		value := values[directive.keyword]
+
+		if value == nil {
+			value = &yaml.Node{}
+			values[directive.keyword] = value
+			mapping.Content = append(mapping.Content, stringNode(directive.keyword), value)
+		}
+
+		switch kind {
+		case headerValueString:
+			*value = *stringNode(directive.value)
+		case headerValueFlag:
+			*value = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"}
+		case headerValueRepeated:
+			switch value.Kind {
+			case 0:
+				*value = *stringNode(directive.value)
+			case yaml.ScalarNode:
+				*value = yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{stringNode(value.Value), stringNode(directive.value)}}
+			default:
+				value.Content = append(value.Content, stringNode(directive.value))
+			}
+		case headerValueArgs:
+			*value = yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+			for _, arg := range directive.args {
+				value.Content = append(value.Content, stringNode(arg))
+			}
+		case headerValuePathReplace:
+			if len(directive.args) != 2 {
+				return nil, fmt.Errorf("failed to parse `pathreplace` header: it requires 2 valuers")
+			}
+			value.Kind = yaml.SequenceNode
+			value.Content = append(value.Content, &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+				stringNode("old"), stringNode(directive.args[0]),
+				stringNode("new"), stringNode(directive.args[1]),
+			}})
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(FrontMatterDelimiter + "\n" + FrontMatterMarker + "\n")
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(mapping); err != nil {
+		return nil, err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	buf.WriteString(FrontMatterDelimiter + "\n")
+
+	return buf.Bytes(), nil
+}
+
+func stringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
@@ -0,0 +1,172 @@
+package templating
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHeadersFrontMatter(t *testing.T) {
+	content := bytes.NewBufferString(`---
+# codegenerator
+filename: "{{ .Name }}.txt"
+pathreplace:
+  - old: some dir
+    new: "{{ .Dir }}"
+delimiters: ["<<<", ">>>"]
+if-not-exists: true
+remove-if-empty: false
+generator-command:
+  - go generate
+  - go vet
+postprocess: gofmt goimports
+merge: concat
+when: .Enabled
+---
+Hello
+---
+World`)
+
+	var header Header
+	body, err := ParseHeaders(content, &header)
+	require.NoError(t, err)
+
+	value, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello\n---\nWorld", string(value))
+	assert.Equal(t, "{{ .Name }}.txt", header.Filename)
+	assert.Equal(t, []pathReplace{{old: "some dir", new: "{{ .Dir }}"}}, header.PathReplace)
+	assert.Equal(t, [2]string{"<<<", ">>>"}, header.Delimiters)
+	assert.True(t, header.IfNotExists)
+	assert.False(t, header.RemoveIfEmpty)
+	assert.Equal(t, []string{"go generate", "go vet"}, header.GeneratorCommands)
+	assert.Equal(t, 11, header.generatorCommandLine(0))
+	assert.Equal(t, 12, header.generatorCommandLine(1))
+	assert.Equal(t, []string{"gofmt", "goimports"}, header.PostProcessors)
+	assert.Equal(t, MergeConcat, header.Merge)
+
+	ok, err := header.When(map[string]interface{}{"Enabled": true})
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestParseHeadersNoFrontMatter(t *testing.T) {
+	for _, content := range []string{
+		"---\napiVersion: v1\nfilename: x\n---\nkind: Service\n",
+		"---\nmode: production\n---\nkind: x\n",
+		"---\nif: true\nwhen: never\n---\n",
+		"---\n- filename\n---\n",
+		"---\n---\nkind: Service\n",
+		"---\n# comment\n---\n",
+		"---\nfilename: [\n---\n",
+		"---\nfilename: unterminated\n",
+		"---\n",
+		"---",
+	} {
+		var header Header
+		body, err := ParseHeaders(bytes.NewBufferString(content), &header)
+		require.NoError(t, err, content)
+
+		value, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(value))
+		assert.Empty(t, header.Filename)
+	}
+}
+
+func TestParseHeadersFrontMatterErrors(t *testing.T) {
+	for content, expected := range map[string]string{
+		"filename: a\nfoo: b\n---\n":                 "unknown template meta-header `foo` on line 4",
+		"apiVersion: v1\n---\n":                      "unknown template meta-header `apiVersion` on line 3",
+		"filename: [a]\n---\n":                       "failed to parse `filename` header on line 3: expected a string",
+		"strict: maybe\n---\n":                       "failed to parse `strict` header on line 3: expected a boolean",
+		"filename: a\nwhen:\n  - .A\n  - (.B\n---\n": "failed to parse `when` header on line 6, column 8: expected `)`",
+		"filename: a\nwhen: \"not\"\n---\n":          "failed to parse `when` header on line 4, column 11: unexpected end of expression",
+		"pathreplace: a b\n---\n":                    "failed to parse `pathreplace` header on line 3: expected a list of `old` and `new` pairs",
+		"merge: all\n---\n":                          "failed to parse `merge` header: unknown strategy `all`",
+		"- filename\n---\n":                          "the front-matter must be a mapping of header keywords",
+		"filename: [\n---\n":                         "parsing front-matter: yaml: line 2: did not find expected node content",
+		"filename: unterminated\n":                   "unterminated front-matter: missing closing `---` line",
+	} {
+		content = "---\n# codegenerator\n" + content
+
+		var header Header
+		_, err := ParseHeaders(bytes.NewBufferString(content), &header)
+		assert.EqualError(t, err, expected, content)
+	}
+}
+
+func TestConvertHeaders(t *testing.T) {
+	legacy := `!!filename {{ .Name }}.txt
+!!pathreplace old new value
+!!when .A
+!!when .B or .C
+!!delimiters <<< >>>
+!!if-not-exists
+!!postprocess gofmt goimports
+!!if .X "y: z"
+body
+`
+	frontMatter := `---
+# codegenerator
+filename: '{{ .Name }}.txt'
+pathreplace:
+  - old: old
+    new: new value
+when:
+  - .A
+  - .B or .C
+delimiters: [<<<, '>>>']
+if-not-exists: true
+postprocess: [gofmt, goimports]
+if: '.X "y: z"'
+---
+body
+`
+
+	out := &bytes.Buffer{}
+	require.NoError(t, ConvertHeaders(bytes.NewBufferString(legacy), out, HeaderFormatFrontMatter))
+	assert.Equal(t, frontMatter, out.String())
+
+	out.Reset()
+	require.NoError(t, ConvertHeaders(bytes.NewBufferString(frontMatter), out, HeaderFormatLegacy))
+	assert.Equal(t, legacy, out.String())
+
+	var legacyHeader, frontMatterHeader Header
+	_, err := ParseHeaders(bytes.NewBufferString(legacy), &legacyHeader)
+	require.NoError(t, err)
+	_, err = ParseHeaders(bytes.NewBufferString(frontMatter), &frontMatterHeader)
+	require.NoError(t, err)
+	assert.Equal(t, legacyHeader.PathReplace, frontMatterHeader.PathReplace)
+	assert.Equal(t, legacyHeader.whenExpressions, frontMatterHeader.whenExpressions)
+	assert.Equal(t, legacyHeader.ifSource, frontMatterHeader.ifSource)
+
+	out.Reset()
+	require.NoError(t, ConvertHeaders(bytes.NewBufferString("no header\n"), out, HeaderFormatFrontMatter))
+	assert.Equal(t, "no header\n", out.String())
+
+	err = ConvertHeaders(bytes.NewBufferString("---\n# codegenerator\npathreplace:\n  - old: some dir\n    new: x\n---\n"), io.Discard, HeaderFormatLegacy)
+	assert.EqualError(t, err, "can't convert `pathreplace` header on line 4: `some dir` can't be empty or contain spaces")
+
+	err = ConvertHeaders(bytes.NewBufferString("---\n# codegenerator\nfilename: |\n  a\n  b\n---\n"), io.Discard, HeaderFormatLegacy)
+	assert.EqualError(t, err, "can't convert `filename` header on line 3: the value spans several lines")
+
+	err = ConvertHeaders(bytes.NewBufferString("!!foo bar\n"), io.Discard, HeaderFormatFrontMatter)
+	assert.EqualError(t, err, "unknown template meta-header `foo` on line 1")
+}
+
+func TestRenderFrontMatter(t *testing.T) {
+	tmpl := loadTemplateString(t, "some dir/a.txt.template", "---\n# codegenerator\nfilename: \"{{ .Name }}.txt\"\npathreplace:\n  - old: some dir\n    new: \"{{ .Dir }}\"\n---\nHello {{ .Name }}\n")
+
+	m := NewMemFS()
+	_, _, err := RenderFS(m, []Template{tmpl}, "output", map[string]string{"Name": "world", "Dir": "pkg"})
+	require.NoError(t, err)
+
+	data, err := m.ReadFile(filepath.Join("output", "pkg", "world.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world\n", string(data))
+}
@@ -1,42 +1,41 @@
 package templating
 
 import (
+	"context"
 	"errors"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"text/template"
 )
 
 type fspack struct {
-	name string
-	fs   fs.ReadDirFS
+	name    string
+	fs      fs.ReadDirFS
+	options packOptions
 }
 
 func (p fspack) GetName() string { return p.name }
-func (p fspack) LoadTemplates() (templates []Template, err error) {
-	err = fs.WalkDir(p.fs, ".", func(path string, d fs.DirEntry, err error) error {
-		if d.IsDir() {
-			return nil
-		}
-		f, err := p.fs.Open(path)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		template, err := LoadTemplate(path, f)
 
-		if err != nil {
-			return err
-		}
+func (p fspack) GetManifest() (*PackManifest, error) {
+	return readPackManifest(p.fs)
+}
+
+func (p fspack) getPartials() (*template.Template, error) {
+	return loadPartials(p.fs)
+}
+
+func (p fspack) LoadTemplates() (templates []Template, err error) {
+	return p.LoadTemplatesContext(context.Background())
+}
 
-		templates = append(templates, template)
-		return nil
-	})
-	return
+func (p fspack) LoadTemplatesContext(ctx context.Context) (templates []Template, err error) {
+	return loadPackTemplates(ctx, p.name, p.fs, p.options)
 }
 
 type fsPackProvider struct {
-	fs fs.FS
+	fs      fs.FS
+	options packOptions
 }
 
 func (p *fsPackProvider) Provide(templateType, templateName string) (Pack, error) {
@@ -44,6 +43,13 @@ func (p *fsPackProvider) Provide(templateType, templateName string) (Pack, error
 	return p.scanPacks(name)
 }
 
+func (p *fsPackProvider) ProvideContext(ctx context.Context, templateType, templateName string) (Pack, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.Provide(templateType, templateName)
+}
+
 func (p *fsPackProvider) scanPacks(name string) (*fspack, error) {
 	_, err := fs.ReadDir(p.fs, name)
 	if err != nil {
@@ -54,22 +60,24 @@ func (p *fsPackProvider) scanPacks(name string) (*fspack, error) {
 		return nil, errors.New("failed cd'ing to pack root")
 	}
 	pack := fspack{
-		name: name,
-		fs:   newRoot.(fs.ReadDirFS),
+		name:    name,
+		fs:      newRoot.(fs.ReadDirFS),
+		options: p.options,
 	}
 	return &pack, nil
 }
 
 //NewFSPackProvider creates a new file system backed pack provider
-func NewFsPackProvider(root string) *fsPackProvider {
+func NewFsPackProvider(root string, options ...PackOption) *fsPackProvider {
 	return &fsPackProvider{
-		fs: os.DirFS(root),
+		fs:      os.DirFS(root),
+		options: newPackOptions(options),
 	}
 }
 
 //RegisterFSPackProviders registers a slice of pack providers for a list of file system roots
-func RegisterFSPackProviders(p PackGroupProvider, roots []string) {
+func RegisterFSPackProviders(p PackGroupProvider, roots []string, options ...PackOption) {
 	for _, root := range roots {
-		p.RegisterProvider(NewFsPackProvider(root))
+		p.RegisterProvider(NewFsPackProvider(root, options...))
 	}
 }
@@ -0,0 +1,43 @@
+package templating
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"go/scanner"
+
+	"golang.org/x/tools/imports"
+)
+
+// FormatGo formats Go source code with go/format and fixes its imports with
+// golang.org/x/tools/imports. The filename is used to resolve the imports.
+//
+// Syntax errors are reported with the line and column of the source.
+func FormatGo(filename string, src []byte) ([]byte, error) {
+	formatted, err := format.Source(src)
+
+	if err != nil {
+		return nil, describeSyntaxError(err)
+	}
+
+	if formatted, err = imports.Process(filename, formatted, &imports.Options{
+		Comments:  true,
+		TabIndent: true,
+		TabWidth:  8,
+	}); err != nil {
+		return nil, describeSyntaxError(err)
+	}
+
+	return formatted, nil
+}
+
+// describeSyntaxError reports the position of the first of a list of syntax errors.
+func describeSyntaxError(err error) error {
+	var list scanner.ErrorList
+
+	if errors.As(err, &list) && len(list) > 0 {
+		return fmt.Errorf("line %d, column %d: %s", list[0].Pos.Line, list[0].Pos.Column, list[0].Msg)
+	}
+
+	return err
+}
@@ -0,0 +1,44 @@
+package templating
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatGo(t *testing.T) {
+	src := "package foo\nfunc Hello( ) string {\nreturn fmt.Sprint(\"hello\")\n}\n"
+
+	formatted, err := FormatGo("foo.go", []byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, "package foo\n\nimport \"fmt\"\n\nfunc Hello() string {\n\treturn fmt.Sprint(\"hello\")\n}\n", string(formatted))
+
+	_, err = FormatGo("foo.go", []byte("package foo\n\nfunc Hello() {\n\treturn (\n}\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 5")
+}
+
+func TestRenderFormatGoSource(t *testing.T) {
+	goTemplate, err := LoadTemplate("main.go.template", bytes.NewBufferString("package {{ . }}\nfunc main( ) { fmt.Println( \"hello\" ) }\n"))
+	require.NoError(t, err)
+
+	m := NewMemFS()
+	r := NewRenderer(WithOutputFS(m), WithFormatGoSource(true))
+	result, err := r.Render([]Template{goTemplate}, "output", "main")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"go", "generate", "./main.go"}}, result.Commands())
+
+	data, err := m.ReadFile(filepath.Join("output", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"hello\") }\n", string(data))
+
+	brokenTemplate, err := LoadTemplate("broken.go.template", bytes.NewBufferString("package {{ . }}\n\nfunc main() {\n"))
+	require.NoError(t, err)
+
+	_, err = r.Render([]Template{brokenTemplate}, "output", "main")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "template `broken.go.template`: post-processor `go`: line 3")
+}
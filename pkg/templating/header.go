@@ -4,8 +4,8 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
-	"html/template"
 	"io"
+	"io/fs"
 	"regexp"
 	"strings"
 )
@@ -27,87 +27,273 @@ type Header struct {
 	GeneratorCommands []string
 	RemoveIfEmpty     bool
 	NoGoGenerate      bool
+	// PostProcessors are the names of the post-processors to apply instead of
+	// the ones registered for the file, or `none` to disable post-processing.
+	PostProcessors []string
+	// Merge is the strategy to combine the output of the template with the
+	// ones of other templates rendering to the same file.
+	Merge MergeStrategy
+	// Foreach returns the collection the template is rendered for, once per
+	// element, see ForeachContext.
+	Foreach func(ctx interface{}) (interface{}, error)
+	// Strict enables the strict rendering of the template, see WithStrict.
+	Strict bool
+	// When reports whether all the `!!when` expressions of the template are
+	// true.
+	When func(ctx interface{}) (bool, error)
+	// Mode are the permission bits of the rendered file, 0 to create it with
+	// 0666, before umask, and keep the ones of an existing file.
+	Mode fs.FileMode
+	// Extensions are the values of the custom directives, by keyword, see
+	// HeaderDirectiveRegistry.
+	Extensions map[string]interface{}
+
+	// generatorCommandLines holds the line number of each generator command.
+	generatorCommandLines []int
+	// ifSource, ifOrSource and foreachSource are the sources of the
+	// conditions and of the collection, evaluated with the functions of the
+	// Renderer.
+	ifSource      string
+	ifOrSource    string
+	foreachSource string
+	// whenExpressions are the parsed `!!when` expressions.
+	whenExpressions []whenNode
+}
+
+// generatorCommandLine returns the line number of the i-th generator command,
+// or 0 if it is unknown.
+func (h Header) generatorCommandLine(i int) int {
+	if i < len(h.generatorCommandLines) {
+		return h.generatorCommandLines[i]
+	}
+
+	return 0
 }
 
 var headerRegexp = regexp.MustCompile(`^!!([a-z-_]+)(?:(?:[ \t]+)(.*))?$`)
 
-// ParseHeaders parses the headers of a template file.
+// A headerDirective is a directive of a header, such as `!!filename` or the
+// `filename` key of a front-matter.
+type headerDirective struct {
+	keyword string
+	value   string
+	// args are the values of the `pathreplace`, `delimiters` and
+	// `postprocess` directives.
+	args []string
+	// line and column are the position of the value in the template file.
+	line   int
+	column int
+}
+
+// ParseHeaders parses the headers of a template file, either `!!keyword value`
+// lines or a YAML front-matter, see FrontMatterDelimiter.
+//
+// Custom directives are looked up in HeaderDirectives.
 func ParseHeaders(r io.Reader, header *Header) (body io.Reader, err error) {
+	return HeaderDirectives.ParseHeaders(r, header)
+}
+
+// ParseHeaders is like the package level ParseHeaders but looks up the custom
+// directives in the registry.
+func (r *HeaderDirectiveRegistry) ParseHeaders(reader io.Reader, header *Header) (body io.Reader, err error) {
+	directives, body, err := r.readHeaderDirectives(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, directive := range directives {
+		if err := header.applyDirective(directive, r); err != nil {
+			return nil, err
+		}
+	}
+
+	return body, nil
+}
+
+// readHeaderDirectives reads the directives of the headers of a template
+// file, in either format.
+func (r *HeaderDirectiveRegistry) readHeaderDirectives(source io.Reader) ([]headerDirective, io.Reader, error) {
+	reader := bufio.NewReader(source)
+	frontMatter, directives, err := r.readFrontMatter(reader)
+
+	if err != nil || directives != nil {
+		return directives, reader, err
+	}
+
+	return readLegacyHeaders(io.MultiReader(bytes.NewReader(frontMatter), reader))
+}
+
+// readLegacyHeaders reads `!!keyword value` header lines.
+func readLegacyHeaders(r io.Reader) ([]headerDirective, io.Reader, error) {
 	reader := bufio.NewReader(r)
+	var directives []headerDirective
 	var line string
+	var err error
 	lineNumber := 0
 
 	for {
 		if line, err = reader.ReadString('\n'); err != nil {
 			// If we fail to read a complete line, let's return it without error.
-			return bytes.NewBufferString(line), nil
+			return directives, bytes.NewBufferString(line), nil
 		}
 
 		lineNumber++
 
-		if match := headerRegexp.FindStringSubmatch(strings.TrimSpace(line)); match == nil {
+		trimmed := strings.TrimSpace(line)
+		match := headerRegexp.FindStringSubmatch(trimmed)
+
+		if match == nil {
 			break
-		} else {
-			keyword := match[1]
-			value := match[2]
-			switch keyword {
-			case "filename":
-				header.Filename = value
-			case "pathreplace":
-				parts := strings.SplitN(value, " ", 2)
-				if len(parts) != 2 {
-					return nil, fmt.Errorf("failed to parse `pathreplace` header: it requires 2 valuers")
-				}
-				header.PathReplace = append(header.PathReplace, pathReplace{
-					old: parts[0],
-					new: parts[1],
-				})
-			case "delimiters":
-				parts := strings.SplitN(value, " ", 2)
-				copy(header.Delimiters[:], parts)
-			case "if":
-				condition := fmt.Sprintf("{{ if and %s }}X{{ end }}", value)
-
-				tmpl, err := template.New("").Parse(condition)
-
-				if err != nil {
-					return nil, fmt.Errorf("failed to parse conditional `if` header: %s", err)
-				}
-
-				header.If = func(ctx interface{}) (bool, error) {
-					buf := &bytes.Buffer{}
-					err := tmpl.Execute(buf, ctx)
-
-					return buf.Len() > 0, err
-				}
-			case "ifor":
-				condition := fmt.Sprintf("{{ if or %s }}X{{ end }}", value)
-
-				tmpl, err := template.New("").Parse(condition)
-
-				if err != nil {
-					return nil, fmt.Errorf("failed to parse conditional `if` header: %s", err)
-				}
-
-				header.IfOr = func(ctx interface{}) (bool, error) {
-					buf := &bytes.Buffer{}
-					err := tmpl.Execute(buf, ctx)
-
-					return buf.Len() > 0, err
-				}
-			case "if-not-exists":
-				header.IfNotExists = true
-			case "generator-command":
-				header.GeneratorCommands = append(header.GeneratorCommands, value)
-			case "remove-if-empty":
-				header.RemoveIfEmpty = true
-			case "no-go-generate":
-				header.NoGoGenerate = true
-			default:
-				return nil, fmt.Errorf("unknown template meta-header `%s` on line %d", keyword, lineNumber)
+		}
+
+		directive := headerDirective{
+			keyword: match[1],
+			value:   match[2],
+			line:    lineNumber,
+			column:  len(line) - len(strings.TrimLeft(line, " \t")) + len(trimmed) - len(match[2]) + 1,
+		}
+
+		switch directive.keyword {
+		case "pathreplace":
+			directive.args = strings.SplitN(directive.value, " ", 2)
+		case "delimiters":
+			directive.args = strings.SplitN(directive.value, " ", 2)
+		case "postprocess":
+			directive.args = strings.Fields(directive.value)
+		}
+
+		directives = append(directives, directive)
+	}
+
+	return directives, io.MultiReader(bytes.NewBufferString(line), reader), nil
+}
+
+// applyDirective sets the fields of the header corresponding to a directive,
+// parsing the custom ones with the registry.
+func (h *Header) applyDirective(directive headerDirective, directives *HeaderDirectiveRegistry) error {
+	value := directive.value
+
+	switch directive.keyword {
+	case "filename":
+		h.Filename = value
+	case "pathreplace":
+		if len(directive.args) != 2 {
+			return fmt.Errorf("failed to parse `pathreplace` header: it requires 2 valuers")
+		}
+		h.PathReplace = append(h.PathReplace, pathReplace{
+			old: directive.args[0],
+			new: directive.args[1],
+		})
+	case "delimiters":
+		copy(h.Delimiters[:], directive.args)
+	case "if":
+		condition := fmt.Sprintf("{{ if and %s }}X{{ end }}", value)
+
+		if err := checkTemplateSyntax(condition); err != nil {
+			return fmt.Errorf("failed to parse conditional `if` header: %s", err)
+		}
+
+		h.ifSource = condition
+		h.If = func(ctx interface{}) (bool, error) {
+			return evaluateCondition(condition, ctx, defaultFuncMap())
+		}
+	case "ifor":
+		condition := fmt.Sprintf("{{ if or %s }}X{{ end }}", value)
+
+		if err := checkTemplateSyntax(condition); err != nil {
+			return fmt.Errorf("failed to parse conditional `if` header: %s", err)
+		}
+
+		h.ifOrSource = condition
+		h.IfOr = func(ctx interface{}) (bool, error) {
+			return evaluateCondition(condition, ctx, defaultFuncMap())
+		}
+	case "when":
+		expression, err := parseWhen(value)
+
+		if err != nil {
+			column := directive.column
+
+			if syntaxErr, ok := err.(*whenSyntaxError); ok {
+				column += syntaxErr.Offset
 			}
+
+			return fmt.Errorf("failed to parse `when` header on line %d, column %d: %s", directive.line, column, err)
+		}
+
+		expressions := append(h.whenExpressions, expression)
+		h.whenExpressions = expressions
+		h.When = func(ctx interface{}) (bool, error) {
+			return evaluateWhenExpressions(expressions, ctx, defaultFuncMap())
+		}
+	case "foreach":
+		if value == "" {
+			return fmt.Errorf("failed to parse `foreach` header: it requires 1 value")
+		}
+
+		if err := checkTemplateSyntax(foreachSource(value)); err != nil {
+			return fmt.Errorf("failed to parse `foreach` header: %s", err)
 		}
+
+		h.foreachSource = value
+		h.Foreach = func(ctx interface{}) (interface{}, error) {
+			return evaluateForeach(value, ctx, defaultFuncMap())
+		}
+	case "if-not-exists":
+		h.IfNotExists = true
+	case "generator-command":
+		h.GeneratorCommands = append(h.GeneratorCommands, value)
+		h.generatorCommandLines = append(h.generatorCommandLines, directive.line)
+	case "remove-if-empty":
+		h.RemoveIfEmpty = true
+	case "no-go-generate":
+		h.NoGoGenerate = true
+	case "strict":
+		h.Strict = true
+	case "mode":
+		mode, err := parseFileMode(value)
+
+		if err != nil {
+			return fmt.Errorf("failed to parse `mode` header: %s", err)
+		}
+
+		h.Mode = mode
+	case "postprocess":
+		names := directive.args
+		if len(names) == 0 {
+			return fmt.Errorf("failed to parse `postprocess` header: it requires at least 1 value")
+		}
+		if containsString(names, postProcessNone) && len(names) > 1 {
+			return fmt.Errorf("failed to parse `postprocess` header: `%s` can't be combined with other values", postProcessNone)
+		}
+		h.PostProcessors = names
+	case "merge":
+		switch strategy := MergeStrategy(value); strategy {
+		case MergeConcat, MergeRegions:
+			h.Merge = strategy
+		default:
+			return fmt.Errorf("failed to parse `merge` header: unknown strategy `%s`", value)
+		}
+	default:
+		parse, ok := directives.Lookup(directive.keyword)
+
+		if !ok {
+			return fmt.Errorf("unknown template meta-header `%s` on line %d", directive.keyword, directive.line)
+		}
+
+		extension, err := parse(value, h.Extensions[directive.keyword])
+
+		if err != nil {
+			return fmt.Errorf("failed to parse `%s` header on line %d: %s", directive.keyword, directive.line, err)
+		}
+
+		if h.Extensions == nil {
+			h.Extensions = map[string]interface{}{}
+		}
+
+		h.Extensions[directive.keyword] = extension
 	}
 
-	return io.MultiReader(bytes.NewBufferString(line), reader), nil
+	return nil
 }
@@ -218,3 +218,32 @@ func TestParseHeadersIfOr(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestHeaderConditionsFuncs(t *testing.T) {
+	var header Header
+	_, err := ParseHeaders(bytes.NewBufferString("!!if (hasKey . \"Name\") (eq (ToGoName .Name) \"APIServer\")\n!!ifor (IsEnabled .Name) false\n"), &header)
+	require.NoError(t, err)
+
+	ctx := map[string]interface{}{"Name": "api server"}
+
+	// The package level functions are used by If, the custom ones aren't available.
+	ok, err := header.If(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, err = header.IfOr(ctx)
+	assert.Error(t, err)
+
+	enabled := NewRenderer(WithFuncs(map[string]interface{}{"IsEnabled": func(string) bool { return true }}))
+	ok, _, err = header.evaluateIf(ctx, enabled.FuncMap())
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	disabled := NewRenderer(WithFuncs(map[string]interface{}{"IsEnabled": func(string) bool { return false }}))
+	ok, keyword, err := header.evaluateIf(ctx, disabled.FuncMap())
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "ifor", keyword)
+
+	_, err = ParseHeaders(bytes.NewBufferString("!!if (.Name\n"), &header)
+	assert.Error(t, err)
+}
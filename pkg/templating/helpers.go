@@ -13,13 +13,18 @@ import (
 
 	"strings"
 
+	sprig "github.com/Masterminds/sprig/v3"
 	"gopkg.in/yaml.v3"
 )
 
+// DefaultCodeGeneratorName is the name of the generator mentioned in the
+// headers of the generated files by default.
+const DefaultCodeGeneratorName = "cestus.io codegenerator"
+
 var CodeGeneratorName string
 
 func init() {
-	CodeGeneratorName = "cestus.io codegenerator"
+	CodeGeneratorName = DefaultCodeGeneratorName
 }
 
 // indexOfInitialisms is a thread-safe implementation of the sorted index of initialisms.
@@ -167,19 +172,6 @@ type (
 	splitterOption func(*splitter) *splitter
 )
 
-// split calls the splitter; splitter provides more control and post options
-func split(str string) []string {
-	lexems := newSplitter().split(str)
-	result := make([]string, 0, len(lexems))
-
-	for _, lexem := range lexems {
-		result = append(result, lexem.GetOriginal())
-	}
-
-	return result
-
-}
-
 func (s *splitter) split(str string) []nameLexem {
 	return s.toNameLexems(str)
 }
@@ -197,6 +189,14 @@ func newSplitter(options ...splitterOption) *splitter {
 	return splitter
 }
 
+// withInitialisms sets the initialisms to split on
+func withInitialisms(initialisms []string) splitterOption {
+	return func(s *splitter) *splitter {
+		s.initialisms = initialisms
+		return s
+	}
+}
+
 // withPostSplitInitialismCheck allows to catch initialisms after main split process
 func withPostSplitInitialismCheck(s *splitter) *splitter {
 	s.postSplitInitialismCheck = true
@@ -498,8 +498,84 @@ var ToGoPackageName = func(name string) string {
 }
 
 // ToGoName sanitizes a name for a public Go variable.
-func ToGoName(name string) string {
-	lexems := newSplitter(withPostSplitInitialismCheck).split(name)
+func ToGoName(name string) string { return defaultNameConverter().ToGoName(name) }
+
+// ToVarName sanitizes a name for a Go variable.
+func ToVarName(name string) string { return defaultNameConverter().ToVarName(name) }
+
+// ToFileName sanitizes a name for a filename.
+func ToFileName(name string) string { return defaultNameConverter().ToFileName(name) }
+
+// ToCommandName lowercases and dashes a go type name
+func ToCommandName(name string) string { return defaultNameConverter().ToCommandName(name) }
+
+// ToEnvVarName uppercases and underscores a name
+func ToEnvVarName(name string) string { return defaultNameConverter().ToEnvVarName(name) }
+
+// ToHumanNameTitle represents a code name as a human series of words with the first letters titleized
+func ToHumanNameTitle(name string) string { return defaultNameConverter().ToHumanNameTitle(name) }
+
+// A NameConverter converts names with its own initialisms and Go name prefix
+// rule, instead of the package level ones.
+type NameConverter struct {
+	initialisms  []string
+	isInitialism func(string) bool
+	prefixFunc   func(string) string
+}
+
+// NewNameConverter creates a name converter keeping the specified initialisms
+// as whole uppercased words.
+//
+// prefixFunc is an optional rule to prefix go names which do not start with a
+// letter, see GoNamePrefixFunc.
+func NewNameConverter(initialisms []string, prefixFunc func(string) string) *NameConverter {
+	configured := make(map[string]bool, len(initialisms))
+	for _, initialism := range initialisms {
+		configured[initialism] = true
+	}
+	index := newIndexOfInitialisms().load(configured)
+
+	return &NameConverter{
+		initialisms:  index.sorted(),
+		isInitialism: index.isInitialism,
+		prefixFunc:   prefixFunc,
+	}
+}
+
+// DefaultInitialisms returns the initialisms used by the package level functions.
+func DefaultInitialisms() []string {
+	return append([]string{}, initialisms...)
+}
+
+// defaultNameConverter returns a name converter using the package level settings.
+func defaultNameConverter() *NameConverter {
+	return &NameConverter{
+		initialisms:  initialisms,
+		isInitialism: isInitialism,
+		prefixFunc:   GoNamePrefixFunc,
+	}
+}
+
+// split calls the splitter; splitter provides more control and post options
+func (c *NameConverter) split(str string) []string {
+	lexems := newSplitter(withInitialisms(c.initialisms)).split(str)
+	result := make([]string, 0, len(lexems))
+
+	for _, lexem := range lexems {
+		result = append(result, lexem.GetOriginal())
+	}
+
+	return result
+}
+
+// ToGoPackageName returns lowercase string without separator.
+func (c *NameConverter) ToGoPackageName(name string) string {
+	return strings.ToLower(c.ToGoName(name))
+}
+
+// ToGoName sanitizes a name for a public Go variable.
+func (c *NameConverter) ToGoName(name string) string {
+	lexems := newSplitter(withInitialisms(c.initialisms), withPostSplitInitialismCheck).split(name)
 
 	result := ""
 	for _, lexem := range lexems {
@@ -516,10 +592,10 @@ func ToGoName(name string) string {
 		// Only prefix with X when the first character isn't an ascii letter
 		first := []rune(result)[0]
 		if !unicode.IsLetter(first) || (first > unicode.MaxASCII && !unicode.IsUpper(first)) {
-			if GoNamePrefixFunc == nil {
+			if c.prefixFunc == nil {
 				return "X" + result
 			}
-			result = GoNamePrefixFunc(name) + result
+			result = c.prefixFunc(name) + result
 		}
 		first = []rune(result)[0]
 		if unicode.IsLetter(first) && !unicode.IsUpper(first) {
@@ -531,9 +607,9 @@ func ToGoName(name string) string {
 }
 
 // ToVarName sanitizes a name for a Go variable.
-func ToVarName(name string) string {
-	res := ToGoName(name)
-	if isInitialism(res) {
+func (c *NameConverter) ToVarName(name string) string {
+	res := c.ToGoName(name)
+	if c.isInitialism(res) {
 		return lower(res)
 	}
 	if len(res) <= 1 {
@@ -543,8 +619,8 @@ func ToVarName(name string) string {
 }
 
 // ToFileName sanitizes a name for a filename.
-func ToFileName(name string) string {
-	in := split(name)
+func (c *NameConverter) ToFileName(name string) string {
+	in := c.split(name)
 	out := make([]string, 0, len(in))
 
 	for _, w := range in {
@@ -555,8 +631,8 @@ func ToFileName(name string) string {
 }
 
 // ToCommandName lowercases and dashes a go type name
-func ToCommandName(name string) string {
-	in := split(name)
+func (c *NameConverter) ToCommandName(name string) string {
+	in := c.split(name)
 	out := make([]string, 0, len(in))
 
 	for _, w := range in {
@@ -566,11 +642,11 @@ func ToCommandName(name string) string {
 }
 
 // ToEnvVarName uppercases and underscores a name
-func ToEnvVarName(name string) string {
+func (c *NameConverter) ToEnvVarName(name string) string {
 	if name == ToUpper(name) {
 		return name
 	}
-	in := split(name)
+	in := c.split(name)
 	out := make([]string, 0, len(in))
 
 	for _, w := range in {
@@ -581,8 +657,8 @@ func ToEnvVarName(name string) string {
 }
 
 // ToHumanNameTitle represents a code name as a human series of words with the first letters titleized
-func ToHumanNameTitle(name string) string {
-	in := newSplitter(withPostSplitInitialismCheck).split(name)
+func (c *NameConverter) ToHumanNameTitle(name string) string {
+	in := newSplitter(withInitialisms(c.initialisms), withPostSplitInitialismCheck).split(name)
 
 	out := make([]string, 0, len(in))
 	for _, w := range in {
@@ -606,23 +682,33 @@ func ToYAML(s interface{}) string {
 }
 
 func getHeaderMessage(message string) string {
+	return headerMessage(CodeGeneratorName, message)
+}
+
+func headerMessage(generatorName string, message string) string {
 	return fmt.Sprintf(
 		"Code generated by %s\n\n%s",
-		CodeGeneratorName,
+		generatorName,
 		message,
 	)
 }
 
+const (
+	codeSectionFileMessage   = "Modifications in code regions will be lost during regeneration!"
+	fullyEditableFileMessage = "You CAN edit this file !"
+	nonEditableFileMessage   = "DO NOT EDIT."
+)
+
 // CodeSectionFileHeader return a header for file using code sections.
 func CodeSectionFileHeader() string {
-	return getHeaderMessage("Modifications in code regions will be lost during regeneration!")
+	return getHeaderMessage(codeSectionFileMessage)
 }
 
 // FullyEditableFileHeader return a header for fully editable files.
-func FullyEditableFileHeader() string { return getHeaderMessage("You CAN edit this file !") }
+func FullyEditableFileHeader() string { return getHeaderMessage(fullyEditableFileMessage) }
 
 // NonEditableFileHeader return a header non editable files.
-func NonEditableFileHeader() string { return getHeaderMessage("DO NOT EDIT.") }
+func NonEditableFileHeader() string { return getHeaderMessage(nonEditableFileMessage) }
 
 func commentLines(input, commentChars string) string {
 	result := strings.Replace(commentChars+" "+input, "\n", "\n"+commentChars+" ", -1)
@@ -681,6 +767,18 @@ func Capitalize(s string) string {
 	return strings.ToUpper(string(s[0])) + strings.ToLower(s[1:])
 }
 
+// defaultFuncMap returns the functions available to the templates rendered
+// with the package level settings.
+func defaultFuncMap() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+
+	for name, f := range templatesFuncMap {
+		funcs[name] = f
+	}
+
+	return funcs
+}
+
 var templatesFuncMap = template.FuncMap{
 	"ToGoName":                ToGoName,
 	"ToVarName":               ToVarName,
@@ -0,0 +1,178 @@
+package templating
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A Manifest lists the files generated by a previous Render.
+type Manifest struct {
+	Files []ManifestEntry `yaml:"files"`
+}
+
+// A ManifestEntry describes a generated file.
+type ManifestEntry struct {
+	// Path is the slash separated path of the file, relative to the output root.
+	Path string `yaml:"path"`
+	// Pack is the name of the pack the template was loaded from.
+	Pack string `yaml:"pack,omitempty"`
+	// Template is the path of the template inside its pack.
+	Template string `yaml:"template"`
+	// Hash is the hash of the generated content.
+	Hash string `yaml:"hash"`
+}
+
+// ReadManifest reads the manifest stored in the specified output root.
+//
+// Returns an empty manifest if there is none.
+func ReadManifest(fsys OutputFS, root string) (*Manifest, error) {
+	return defaultRenderer(fsys).ReadManifest(root)
+}
+
+// ReadManifest reads the manifest of the renderer stored in the specified
+// output root.
+func (r *Renderer) ReadManifest(root string) (*Manifest, error) {
+	manifest := &Manifest{}
+
+	if r.manifestFileName == "" {
+		return manifest, nil
+	}
+
+	data, err := r.fsys.ReadFile(filepath.Join(root, r.manifestFileName))
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return manifest, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = yaml.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest `%s`: %s", r.manifestFileName, err)
+	}
+
+	return manifest, nil
+}
+
+func (m *Manifest) marshal() ([]byte, error) {
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+
+	return yaml.Marshal(m)
+}
+
+func (m *Manifest) find(path string) *ManifestEntry {
+	for i := range m.Files {
+		if m.Files[i].Path == path {
+			return &m.Files[i]
+		}
+	}
+
+	return nil
+}
+
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// manifestPath returns the path of a file as recorded in the manifest.
+func manifestPath(root string, path string) string {
+	rel, err := filepath.Rel(root, path)
+
+	if err != nil {
+		rel = path
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// updateManifest computes the manifest resulting from a list of actions and
+// appends the actions pruning the orphans of the previous manifest.
+//
+// An orphan is a file listed in the previous manifest that was not produced
+// again by the packs of the templates. It is only pruned if its content is
+// still the generated one, so that user modifications are never lost. The
+// files of other packs rendered to the same root are left alone.
+func (r *Renderer) updateManifest(previous *Manifest, root string, templates []Template, actions []Action, patterns []string) ([]Action, *Manifest, error) {
+	next := &Manifest{}
+	produced := map[string]bool{}
+	packs := map[string]bool{}
+
+	for _, t := range templates {
+		packs[templatePack(t)] = true
+	}
+
+	for _, action := range actions {
+		path := manifestPath(root, action.Path)
+
+		switch action.Kind {
+		case ActionCreate, ActionUpdate, ActionUnchanged:
+			next.Files = append(next.Files, ManifestEntry{
+				Path:     path,
+				Pack:     action.Pack,
+				Template: action.Template,
+				Hash:     hashContent(action.Content),
+			})
+		case ActionSkipExists:
+			if entry := previous.find(path); entry != nil {
+				next.Files = append(next.Files, *entry)
+			}
+		case ActionRemove:
+			// The file is removed, it is not an orphan.
+		default:
+			continue
+		}
+
+		produced[path] = true
+	}
+
+	for _, entry := range previous.Files {
+		if produced[entry.Path] {
+			continue
+		}
+
+		if !packs[entry.Pack] {
+			// The file was generated by another pack.
+			next.Files = append(next.Files, entry)
+			continue
+		}
+
+		if matched, err := matchPatterns(patterns, filepath.FromSlash(entry.Path)); err != nil {
+			return nil, nil, err
+		} else if !matched {
+			// The file was not considered during this rendering.
+			next.Files = append(next.Files, entry)
+			continue
+		}
+
+		path := filepath.Join(root, filepath.FromSlash(entry.Path))
+		existing, err := r.fsys.ReadFile(path)
+
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		if hashContent(existing) != entry.Hash {
+			// The file was modified since it was generated: leave it alone.
+			continue
+		}
+
+		actions = append(actions, Action{
+			Kind:     ActionPrune,
+			Template: entry.Template,
+			Pack:     entry.Pack,
+			Path:     path,
+			Existing: existing,
+		})
+	}
+
+	return actions, next, nil
+}
@@ -0,0 +1,121 @@
+package templating
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func manifestPaths(t *testing.T, m OutputFS, root string) []string {
+	t.Helper()
+
+	manifest, err := ReadManifest(m, root)
+	require.NoError(t, err)
+
+	paths := []string{}
+	for _, entry := range manifest.Files {
+		paths = append(paths, entry.Path)
+	}
+
+	return paths
+}
+
+// filterTemplates returns the templates with the specified paths.
+func filterTemplates(templates []Template, paths ...string) (filtered []Template) {
+	for _, t := range templates {
+		if containsString(paths, t.GetPath()) {
+			filtered = append(filtered, t)
+		}
+	}
+
+	return filtered
+}
+
+func TestRenderManifest(t *testing.T) {
+	templates := loadFixturePack(t, "plan")
+	m := NewMemFS()
+
+	_, _, err := RenderFS(m, templates, "output", map[string]interface{}{"Name": "world", "Enabled": true})
+	require.NoError(t, err)
+
+	manifest, err := ReadManifest(m, "output")
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 6)
+	assert.Equal(t, ManifestEntry{
+		Path:     "created.txt",
+		Pack:     "plan",
+		Template: "created.txt.template",
+		Hash:     hashContent([]byte("Hello world\n")),
+	}, manifest.Files[0])
+
+	t.Run("condition turned false", func(t *testing.T) {
+		_, _, err := RenderFS(m, templates, "output", map[string]interface{}{"Name": "world", "Enabled": false})
+		require.NoError(t, err)
+
+		_, err = m.ReadFile(filepath.Join("output", "skipped.txt"))
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+		assert.NotContains(t, manifestPaths(t, m, "output"), "skipped.txt")
+	})
+
+	t.Run("patterns keep unrelated entries", func(t *testing.T) {
+		_, _, err := RenderFS(m, filterTemplates(templates, "unchanged.txt"), "output", nil, "updated.txt")
+		require.NoError(t, err)
+
+		_, err = m.ReadFile(filepath.Join("output", "updated.txt"))
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+		_, err = m.ReadFile(filepath.Join("output", "created.txt"))
+		assert.NoError(t, err)
+		assert.Contains(t, manifestPaths(t, m, "output"), "created.txt")
+	})
+
+	t.Run("modified orphans are kept", func(t *testing.T) {
+		require.NoError(t, m.WriteFile(filepath.Join("output", "created.txt"), []byte("Hello me\n"), 0666))
+
+		_, _, err := RenderFS(m, filterTemplates(templates, "skipped.txt.template"), "output", nil)
+		require.NoError(t, err)
+
+		data, err := m.ReadFile(filepath.Join("output", "created.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "Hello me\n", string(data))
+		_, err = m.ReadFile(filepath.Join("output", "unchanged.txt"))
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+		assert.Empty(t, manifestPaths(t, m, "output"))
+	})
+}
+
+func TestRenderWithoutManifest(t *testing.T) {
+	m := NewMemFS()
+	result, err := NewRenderer(WithOutputFS(m), WithManifestFileName("")).Render(loadFixturePack(t, "plan"), "output", map[string]interface{}{"Name": "world"})
+	require.NoError(t, err)
+	assert.Equal(t, result.Generated(), m.Paths())
+}
+
+func TestRenderManifestSeveralPacks(t *testing.T) {
+	packA, err := LoadTemplate("a.txt", strings.NewReader("a\n"))
+	require.NoError(t, err)
+	packB, err := LoadTemplate("b.txt", strings.NewReader("b\n"))
+	require.NoError(t, err)
+	m := NewMemFS()
+
+	_, _, err = RenderFS(m, []Template{withPack(packA, "packA")}, "out", nil)
+	require.NoError(t, err)
+	generated, _, err := RenderFS(m, []Template{withPack(packB, "packB")}, "out", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{filepath.Join("out", "b.txt")}, generated)
+	data, err := m.ReadFile(filepath.Join("out", "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "a\n", string(data))
+	assert.Equal(t, []string{"a.txt", "b.txt"}, manifestPaths(t, m, "out"))
+
+	_, _, err = RenderFS(m, []Template{withPack(packA, "packA")}, "out", nil, "nothing")
+	require.NoError(t, err)
+	_, _, err = RenderFS(m, []Template{}, "out", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "b.txt"}, manifestPaths(t, m, "out"))
+}
@@ -0,0 +1,98 @@
+package templating
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+)
+
+// ChmodFS is implemented by the OutputFS able to set the mode of a file
+// regardless of the umask. Modes set with the `!!mode` header are applied
+// exactly on such file systems, and subject to the umask otherwise.
+type ChmodFS interface {
+	Chmod(name string, mode fs.FileMode) error
+}
+
+// A PackMode sets the mode of the files rendered by the templates of a pack,
+// unless they have a `!!mode` header.
+type PackMode struct {
+	// Pattern is either an extension such as `.sh`, or a glob matched against
+	// the base name of the output file, or against its path relative to the
+	// output root if it contains a path separator.
+	Pattern string `yaml:"pattern"`
+	// Mode are the octal permission bits, e.g. `0755`.
+	Mode string `yaml:"mode"`
+}
+
+// parseFileMode parses octal permission bits such as `0755`.
+func parseFileMode(value string) (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, fmt.Errorf("invalid file mode `%s`, expected octal permission bits such as 0755", value)
+	}
+
+	return fs.FileMode(mode), nil
+}
+
+// packMode is a parsed PackMode.
+type packMode struct {
+	pattern string
+	mode    fs.FileMode
+}
+
+// parsePackModes checks and parses the modes of a pack manifest.
+func parsePackModes(modes []PackMode) ([]packMode, error) {
+	parsed := make([]packMode, 0, len(modes))
+
+	for _, mode := range modes {
+		if _, err := filepath.Match(mode.Pattern, ""); err != nil || mode.Pattern == "" {
+			return nil, fmt.Errorf("invalid mode pattern `%s`", mode.Pattern)
+		}
+
+		perm, err := parseFileMode(mode.Mode)
+
+		if err != nil {
+			return nil, err
+		}
+
+		parsed = append(parsed, packMode{pattern: mode.Pattern, mode: perm})
+	}
+
+	return parsed, nil
+}
+
+// modesTemplate is implemented by the templates loaded from a pack with modes.
+type modesTemplate interface {
+	getModes() []packMode
+}
+
+// withModes records the modes of the pack a template was loaded from.
+func withModes(t Template, modes []packMode) Template {
+	if impl, ok := t.(templateImpl); ok && len(modes) > 0 {
+		impl.modes = modes
+		return impl
+	}
+
+	return t
+}
+
+// fileMode returns the mode of the file rendered by a template, as set by its
+// `!!mode` header or by the first mode of its pack matching the file, or 0 if
+// none is set.
+func fileMode(tmpl Template, relPath string) fs.FileMode {
+	if mode := tmpl.GetHeader().Mode; mode != 0 {
+		return mode
+	}
+
+	if t, ok := tmpl.(modesTemplate); ok {
+		for _, mode := range t.getModes() {
+			if matchPostProcessorPattern(mode.pattern, relPath) {
+				return mode.mode
+			}
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,133 @@
+package templating
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFileMode(t *testing.T) {
+	for value, expected := range map[string]fs.FileMode{"0755": 0755, "644": 0644, "0444": 0444, "0o700": 0} {
+		mode, err := parseFileMode(value)
+
+		if expected == 0 {
+			assert.Error(t, err, value)
+			continue
+		}
+
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, mode, value)
+	}
+
+	for _, value := range []string{"", "0", "0999", "01777", "rwx"} {
+		_, err := parseFileMode(value)
+		assert.EqualError(t, err, "invalid file mode `"+value+"`, expected octal permission bits such as 0755")
+	}
+
+	var header Header
+	_, err := ParseHeaders(bytes.NewBufferString("!!mode 0755\n"), &header)
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0755), header.Mode)
+
+	_, err = ParseHeaders(bytes.NewBufferString("!!mode u+x\n"), &header)
+	assert.EqualError(t, err, "failed to parse `mode` header: invalid file mode `u+x`, expected octal permission bits such as 0755")
+}
+
+func TestRenderModes(t *testing.T) {
+	templates := loadFixturePack(t, "modes")
+	m := NewMemFS()
+	ctx := map[string]string{"Name": "world"}
+
+	_, _, err := RenderFS(m, templates, "output", ctx)
+	require.NoError(t, err)
+
+	for path, expected := range map[string]fs.FileMode{
+		"run.sh":           0755,
+		"private.sh":       0700,
+		"hooks/pre-commit": 0750,
+		"generated.txt":    0444,
+		"readme.txt":       0666,
+	} {
+		info, err := m.Stat(filepath.Join("output", filepath.FromSlash(path)))
+		require.NoError(t, err, path)
+		assert.Equal(t, expected, info.Mode().Perm(), path)
+	}
+
+	// A mode change alone is a modification, files without mode keep theirs.
+	require.NoError(t, m.Chmod(filepath.Join("output", "run.sh"), 0644))
+	require.NoError(t, m.Chmod(filepath.Join("output", "readme.txt"), 0600))
+
+	err = CheckFS(m, templates, "output", ctx)
+	var driftErr *DriftError
+	require.ErrorAs(t, err, &driftErr)
+	require.Len(t, driftErr.Files, 1)
+	assert.Equal(t, DriftStale, driftErr.Files[0].Kind)
+	assert.Equal(t, "old mode 0644\nnew mode 0755\n", driftErr.Files[0].Diff)
+
+	result, err := RenderFSWithResult(m, templates, "output", ctx)
+	require.NoError(t, err)
+
+	for _, file := range result.Files {
+		expected := StatusUnchanged
+		if filepath.Base(file.Path) == "run.sh" {
+			expected = StatusWritten
+		}
+		assert.Equal(t, expected, file.Status, file.Path)
+	}
+
+	info, err := m.Stat(filepath.Join("output", "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0755), info.Mode().Perm())
+	info, err = m.Stat(filepath.Join("output", "readme.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0600), info.Mode().Perm())
+}
+
+func TestRenderModesOS(t *testing.T) {
+	templates := loadFixturePack(t, "modes")
+	root := t.TempDir()
+
+	for _, name := range []string{"world", "again"} {
+		_, _, err := Render(templates, root, map[string]string{"Name": name})
+		require.NoError(t, err)
+	}
+
+	info, err := os.Stat(filepath.Join(root, "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0755), info.Mode().Perm())
+
+	// Read-only files are replaced all the same.
+	data, err := os.ReadFile(filepath.Join(root, "generated.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "Generated, do not edit.\n// region CODE_REGION(Name)\nagain\n// endregion\n", string(data))
+	info, err = os.Stat(filepath.Join(root, "generated.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0444), info.Mode().Perm())
+}
+
+func TestPackModes(t *testing.T) {
+	manifest := &PackManifest{Modes: []PackMode{{Pattern: "[", Mode: "0755"}}}
+	assert.EqualError(t, manifest.Validate(), "invalid mode pattern `[`")
+
+	manifest = &PackManifest{Modes: []PackMode{{Pattern: "*.sh", Mode: "755x"}}}
+	assert.EqualError(t, manifest.Validate(), "invalid file mode `755x`, expected octal permission bits such as 0755")
+
+	base := &PackManifest{Modes: []PackMode{{Pattern: "*.sh", Mode: "0755"}}}
+	child := &PackManifest{Modes: []PackMode{{Pattern: "run.sh", Mode: "0700"}}}
+	assert.Equal(t, []PackMode{{Pattern: "run.sh", Mode: "0700"}, {Pattern: "*.sh", Mode: "0755"}}, base.extend(child).Modes)
+
+	pack, err := NewEmbededPackProvider(fstest.MapFS{
+		"broken/pack.yaml":     &fstest.MapFile{Data: []byte("modes:\n  - pattern: .sh\n    mode: 9\n")},
+		"broken/a.sh.template": &fstest.MapFile{Data: []byte("a\n")},
+	}).Provide("", "broken")
+	require.NoError(t, err)
+
+	_, err = pack.LoadTemplates()
+	assert.EqualError(t, err, "pack manifest `pack.yaml`: invalid file mode `9`, expected octal permission bits such as 0755")
+}
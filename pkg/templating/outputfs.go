@@ -0,0 +1,250 @@
+package templating
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutputFS is a writable file system generated files are rendered to.
+//
+// Implementations must return errors matching fs.ErrNotExist when accessing a
+// file that doesn't exist.
+type OutputFS interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(path string, perm fs.FileMode) error
+	Remove(name string) error
+	Rename(oldpath, newpath string) error
+	Stat(name string) (fs.FileInfo, error)
+}
+
+// ContextFS is implemented by the OutputFS whose operations can be
+// interrupted, such as remote file systems.
+//
+// The renderers call WithContext with the context given to PlanContext,
+// CheckContext and RenderContext, and use the returned file system instead.
+// It should also implement ChmodFS if the original one does. Without it, the
+// context is only checked between files and a slow operation can't be
+// interrupted.
+type ContextFS interface {
+	OutputFS
+	WithContext(ctx context.Context) OutputFS
+}
+
+// withContext returns the file system to use with a context.
+func withContext(fsys OutputFS, ctx context.Context) OutputFS {
+	if contextFS, ok := fsys.(ContextFS); ok {
+		return contextFS.WithContext(ctx)
+	}
+
+	return fsys
+}
+
+type osFS struct{}
+
+func (osFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+func (osFS) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+func (osFS) Remove(name string) error                     { return os.Remove(name) }
+func (osFS) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (osFS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (osFS) Chmod(name string, mode fs.FileMode) error    { return os.Chmod(name, mode) }
+
+// NewOSFS returns an OutputFS backed by the operating system file system.
+func NewOSFS() OutputFS {
+	return osFS{}
+}
+
+var _ OutputFS = (*MemFS)(nil)
+var _ ChmodFS = (*MemFS)(nil)
+
+type memFile struct {
+	data []byte
+	mode fs.FileMode
+}
+
+// MemFS is an in-memory OutputFS.
+//
+// Parent directories are created implicitly when a file is written. It is safe
+// for concurrent use.
+type MemFS struct {
+	mutex sync.RWMutex
+	files map[string]memFile
+	dirs  map[string]bool
+}
+
+// NewMemFS creates an empty in-memory file system.
+func NewMemFS() *MemFS {
+	return &MemFS{
+		files: map[string]memFile{},
+		dirs:  map[string]bool{},
+	}
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	file, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return append([]byte{}, file.data...), nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name = filepath.Clean(name)
+	if m.dirs[name] {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+
+	// Like os.WriteFile, the permissions of an existing file are kept.
+	if file, ok := m.files[name]; ok {
+		perm = file.mode
+	}
+	if err := m.mkdirAll(filepath.Dir(name)); err != nil {
+		return err
+	}
+	m.files[name] = memFile{data: append([]byte{}, data...), mode: perm.Perm()}
+
+	return nil
+}
+
+func (m *MemFS) MkdirAll(path string, perm fs.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.mkdirAll(path)
+}
+
+func (m *MemFS) mkdirAll(path string) error {
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if _, ok := m.files[dir]; ok {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: fs.ErrExist}
+		}
+		m.dirs[dir] = true
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name = filepath.Clean(name)
+	if _, ok := m.files[name]; ok {
+		delete(m.files, name)
+		return nil
+	}
+	if m.dirs[name] {
+		prefix := name + string(filepath.Separator)
+		for path := range m.files {
+			if strings.HasPrefix(path, prefix) {
+				return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+			}
+		}
+		for path := range m.dirs {
+			if strings.HasPrefix(path, prefix) {
+				return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+			}
+		}
+		delete(m.dirs, name)
+		return nil
+	}
+
+	return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+}
+
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	file, ok := m.files[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if m.dirs[newpath] {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrExist}
+	}
+	if err := m.mkdirAll(filepath.Dir(newpath)); err != nil {
+		return err
+	}
+	delete(m.files, oldpath)
+	m.files[newpath] = file
+
+	return nil
+}
+
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name = filepath.Clean(name)
+	file, ok := m.files[name]
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+	}
+	file.mode = mode.Perm()
+	m.files[name] = file
+
+	return nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	name = filepath.Clean(name)
+	if file, ok := m.files[name]; ok {
+		return memFileInfo{name: filepath.Base(name), size: int64(len(file.data)), mode: file.mode}, nil
+	}
+	if m.dirs[name] {
+		return memFileInfo{name: filepath.Base(name), mode: fs.ModeDir | 0755}, nil
+	}
+
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// Paths returns the sorted paths of all the files stored in the file system.
+func (m *MemFS) Paths() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	paths := make([]string, 0, len(m.files))
+	for path := range m.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+type memFileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memFileInfo) Sys() interface{}   { return nil }
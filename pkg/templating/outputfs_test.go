@@ -0,0 +1,63 @@
+package templating
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemFS(t *testing.T) {
+	m := NewMemFS()
+
+	_, err := m.ReadFile("out/a.txt")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	require.NoError(t, m.MkdirAll("out/sub", 0755))
+	require.NoError(t, m.WriteFile("out/sub/../a.txt", []byte("hello"), 0666))
+
+	data, err := m.ReadFile("out/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, []string{filepath.Join("out", "a.txt")}, m.Paths())
+
+	assert.Error(t, m.WriteFile("out/sub", nil, 0666))
+	assert.Error(t, m.MkdirAll("out/a.txt/sub", 0755))
+
+	require.NoError(t, m.Remove("out/a.txt"))
+	assert.True(t, errors.Is(m.Remove("out/a.txt"), fs.ErrNotExist))
+	assert.Empty(t, m.Paths())
+}
+
+func TestRenderFS(t *testing.T) {
+	templates := loadFixturePack(t, "foo")
+	referencePath := "fixtures/templates/reference"
+	outputPath := "output"
+	m := NewMemFS()
+
+	require.NoError(t, m.WriteFile(filepath.Join(outputPath, "d.txt"), []byte("Updated text.\n\n// region CODE_REGION(Foo)\nText that will be lost\n// endregion\n\nThere too.\n"), 0666))
+	require.NoError(t, m.WriteFile(filepath.Join(outputPath, "f.txt"), []byte("This is some stuff.\n"), 0666))
+
+	generatedFiles, _, err := RenderFS(m, templates, outputPath, "world")
+	require.NoError(t, err)
+	assert.Equal(t, append([]string{filepath.Join(outputPath, DefaultManifestFileName)}, generatedFiles...), m.Paths())
+
+	for _, path := range generatedFiles {
+		rel, err := filepath.Rel(outputPath, path)
+		require.NoError(t, err)
+
+		reference, err := os.ReadFile(filepath.Join(referencePath, rel))
+		require.NoError(t, err)
+
+		data, err := m.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, string(reference), string(data), path)
+	}
+
+	_, err = os.Stat(outputPath)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -1,9 +1,13 @@
 package templating
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"path/filepath"
 	"strings"
+	"text/template"
 
 	"code.cestus.io/libs/codegenerator/pkg/locations"
 )
@@ -11,20 +15,224 @@ import (
 // Pack represents a template source.
 type Pack interface {
 	GetName() string
+	// GetManifest returns the manifest of the pack, or an empty manifest if
+	// it has none, see PackManifestFileName.
+	GetManifest() (*PackManifest, error)
 	LoadTemplates() ([]Template, error)
 }
 
+// ContextPack is a Pack which stops loading its templates once a context is done.
+type ContextPack interface {
+	Pack
+	LoadTemplatesContext(ctx context.Context) ([]Template, error)
+}
+
+// LoadTemplatesContext loads the templates of a pack, aborting with the error
+// of the context once it is done.
+//
+// Packs which don't implement ContextPack are only checked before loading.
+func LoadTemplatesContext(ctx context.Context, pack Pack) ([]Template, error) {
+	if p, ok := pack.(ContextPack); ok {
+		return p.LoadTemplatesContext(ctx)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return pack.LoadTemplates()
+}
+
 // LoadPack returns a template pack for the specified template type and name.
-func LoadPack(templateType, templateName string) (Pack, error) {
+//
+// The manifests of the pack and of the packs it extends are validated.
+func LoadPack(templateType, templateName string, options ...PackOption) (Pack, error) {
+	return LoadPackContext(context.Background(), templateType, templateName, options...)
+}
+
+// LoadPackContext is like LoadPack but aborts once the context is done.
+func LoadPackContext(ctx context.Context, templateType, templateName string, options ...PackOption) (Pack, error) {
 	name := filepath.Join(templateType, templateName)
 	templatesRoots := GetTemplatesRoots()
 	pp := NewPackProvider()
-	RegisterFSPackProviders(pp, templatesRoots)
-	pack, err := pp.Provide("", name)
+	RegisterFSPackProviders(pp, templatesRoots, options...)
+	pack, err := pp.ProvideContext(ctx, "", name)
 
-	if err != nil {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+
+	if errors.Is(err, errNoPackFound) {
 		return nil, fmt.Errorf("no such pack \"%s\" found in: %s, use `%s` environement variable to set a custom root location", name, strings.Join(templatesRoots, ", "), locations.EnvCestusTemplateRoot)
+	} else if err != nil {
+		return nil, err
 	}
 
 	return pack, nil
 }
+
+// loadPackTemplates loads the templates of a pack file system, along with its
+// partials, schema, modes and manifest, which are never rendered.
+func loadPackTemplates(ctx context.Context, name string, fsys fs.ReadDirFS, options packOptions) (templates []Template, err error) {
+	partials, err := loadPartials(fsys)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := readSchema(fsys)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := readPackManifest(fsys)
+	if err != nil {
+		return nil, err
+	}
+	modes, err := parsePackModes(manifest.Modes)
+	if err != nil {
+		return nil, fmt.Errorf("pack manifest `%s`: %s", PackManifestFileName, err)
+	}
+	err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path == PartialsDir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if path == PackManifestFileName || path == SchemaFileName {
+			return nil
+		}
+		f, err := fsys.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		template, err := options.headerDirectives.LoadTemplate(path, f)
+
+		if err != nil {
+			return err
+		}
+
+		templates = append(templates, withPackManifest(withModes(withSchema(withPartials(withPack(template, name), partials), schema), modes), manifest))
+		return nil
+	})
+	return
+}
+
+// extendedPack is a pack extending a base pack. Its templates override the
+// ones of the base pack with the same path, and its partials the base defines
+// with the same name.
+type extendedPack struct {
+	Pack
+	base Pack
+}
+
+func (p *extendedPack) GetManifest() (*PackManifest, error) {
+	manifest, err := p.Pack.GetManifest()
+
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := p.base.GetManifest()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return base.extend(manifest), nil
+}
+
+func (p *extendedPack) getPartials() (*template.Template, error) {
+	base, err := packPartials(p.base)
+
+	if err != nil {
+		return nil, err
+	}
+
+	own, err := packPartials(p.Pack)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mergePartials(base, own)
+}
+
+func (p *extendedPack) LoadTemplates() ([]Template, error) {
+	return p.LoadTemplatesContext(context.Background())
+}
+
+func (p *extendedPack) LoadTemplatesContext(ctx context.Context) ([]Template, error) {
+	baseTemplates, err := LoadTemplatesContext(ctx, p.base)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ownTemplates, err := LoadTemplatesContext(ctx, p.Pack)
+
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := map[string]Template{}
+
+	for _, template := range ownTemplates {
+		overrides[overrideKey(template)] = template
+	}
+
+	// Overridden templates keep the position of the base ones.
+	templates := make([]Template, 0, len(baseTemplates)+len(ownTemplates))
+
+	for _, template := range baseTemplates {
+		name := overrideKey(template)
+
+		if override, ok := overrides[name]; ok {
+			template = override
+			delete(overrides, name)
+		}
+		templates = append(templates, template)
+	}
+
+	for _, template := range ownTemplates {
+		if _, ok := overrides[overrideKey(template)]; ok {
+			templates = append(templates, template)
+		}
+	}
+
+	// The modes, required keys and defaults of the whole inheritance chain
+	// apply to all the templates.
+	manifest, err := p.GetManifest()
+
+	if err != nil {
+		return nil, err
+	}
+
+	modes, err := parsePackModes(manifest.Modes)
+
+	if err != nil {
+		return nil, fmt.Errorf("pack `%s`: %s", p.GetName(), err)
+	}
+
+	// So do the partials, the base templates use the overriding defines.
+	partials, err := p.getPartials()
+
+	if err != nil {
+		return nil, fmt.Errorf("pack `%s`: %s", p.GetName(), err)
+	}
+
+	for i, template := range templates {
+		templates[i] = withPackManifest(withModes(withPartials(template, partials), modes), manifest)
+	}
+
+	return templates, nil
+}
+
+// overrideKey returns the key matching a template with the one it overrides:
+// its path in the pack, without the `.template` extension, so that a template
+// can override a static file.
+func overrideKey(t Template) string {
+	return strings.TrimSuffix(t.GetPath(), ".template")
+}
@@ -0,0 +1,127 @@
+package templating
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTemplatesContext(t *testing.T) {
+	pp := NewPackProvider()
+	RegisterFSPackProviders(pp, []string{"fixtures/templates"})
+	require.NoError(t, pp.RegisterProvider(NewEmbededPackProvider(fstest.MapFS{
+		"embedded/a.txt": &fstest.MapFile{Data: []byte("a\n")},
+	})))
+
+	for _, name := range []string{"foo", "embedded"} {
+		pack, err := ProvideContext(context.Background(), pp, "", name)
+		require.NoError(t, err)
+
+		templates, err := LoadTemplatesContext(context.Background(), pack)
+		require.NoError(t, err)
+		assert.NotEmpty(t, templates)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		templates, err = LoadTemplatesContext(ctx, pack)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, templates)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := ProvideContext(ctx, pp, "", "foo")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLoadTemplatesPartials(t *testing.T) {
+	templates := loadFixturePack(t, "partials")
+	require.Len(t, templates, 2)
+
+	m := NewMemFS()
+	_, _, err := RenderFS(m, templates, "output", map[string]string{"Owner": "ACME", "Name": "world"})
+	require.NoError(t, err)
+
+	data, err := m.ReadFile(filepath.Join("output", "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "// Licensed to ACME\nHello WORLD\n", string(data))
+	data, err = m.ReadFile(filepath.Join("output", "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "// Licensed to ACME {{ .Raw }}\n", string(data))
+
+	pack, err := NewEmbededPackProvider(fstest.MapFS{
+		"broken/_partials/broken.tmpl": &fstest.MapFile{Data: []byte("{{ define \"broken\" }}")},
+		"broken/a.txt.template":        &fstest.MapFile{Data: []byte("a\n")},
+	}).Provide("", "broken")
+	require.NoError(t, err)
+
+	_, err = pack.LoadTemplates()
+	assert.ErrorContains(t, err, "parsing partial `_partials/broken.tmpl`")
+
+	// Partials can call the functions of the renderer.
+	pack, err = NewEmbededPackProvider(fstest.MapFS{
+		"funcs/_partials/team.tmpl": &fstest.MapFile{Data: []byte("{{ define \"team\" }}{{ Team }}{{ end }}")},
+		"funcs/a.txt.template":      &fstest.MapFile{Data: []byte("{{ template \"team\" . }}\n")},
+	}).Provide("", "funcs")
+	require.NoError(t, err)
+
+	templates, err = pack.LoadTemplates()
+	require.NoError(t, err)
+
+	m = NewMemFS()
+	_, err = NewRenderer(WithOutputFS(m), WithFuncs(map[string]interface{}{"Team": func() string { return "team-a" }})).Render(templates, "output", nil)
+	require.NoError(t, err)
+
+	data, err = m.ReadFile(filepath.Join("output", "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "team-a\n", string(data))
+
+	_, err = NewRenderer(WithOutputFS(NewMemFS())).Render(templates, "output", nil)
+	assert.ErrorContains(t, err, "\"Team\" is not a defined function")
+}
+
+func TestProvideExtendedPack(t *testing.T) {
+	pp := NewPackProvider()
+	RegisterFSPackProviders(pp, []string{"fixtures/templates"})
+
+	pack, err := pp.Provide("inherit", "child")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("inherit", "child"), pack.GetName())
+
+	templates, err := pack.LoadTemplates()
+	require.NoError(t, err)
+
+	m := NewMemFS()
+	result, err := RenderFSWithResult(m, templates, "output", map[string]interface{}{"Name": "world"})
+	require.NoError(t, err)
+
+	var packs []string
+	for _, file := range result.Files {
+		packs = append(packs, file.Pack)
+		data, err := m.ReadFile(file.Path)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"a.txt": "child a\n",
+			"b.txt": "middle b\n",
+			"c.txt": "middle c\n",
+			"d.txt": "child d\n",
+			// A template overrides a static file rendering to the same file.
+			"e.txt": "child e, hello\n",
+			// Base templates use the partials overridden by the child.
+			"f.txt": "hello from child\n",
+			// Templates with the same path override each other whatever
+			// their filename.
+			"world.txt": "child g\n",
+		}[filepath.Base(file.Path)], string(data), file.Path)
+	}
+	assert.Equal(t, []string{"inherit/child", "inherit/middle", "inherit/child", "inherit/base", "inherit/child", "inherit/middle", "inherit/child"}, packs)
+
+	_, err = pp.Provide("inherit", "cycle-a")
+	assert.EqualError(t, err, "resolving pack `inherit/cycle-b` extended by `inherit/cycle-a`: pack inheritance cycle: inherit/cycle-a -> inherit/cycle-b -> inherit/cycle-a")
+}
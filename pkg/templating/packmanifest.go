@@ -0,0 +1,267 @@
+package templating
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// PackManifestFileName is the name of the optional manifest at the root of a
+// pack. It is never rendered.
+const PackManifestFileName = "pack.yaml"
+
+// A PackManifest describes a pack.
+type PackManifest struct {
+	// Description describes what the pack generates.
+	Description string `yaml:"description,omitempty"`
+	// Version is the semantic version of the pack.
+	Version string `yaml:"version,omitempty"`
+	// Authors are the authors of the pack.
+	Authors []string `yaml:"authors,omitempty"`
+	// MinCodegeneratorVersion is the minimum version of the codegenerator
+	// required to render the pack, see Version.
+	MinCodegeneratorVersion string `yaml:"min-codegenerator-version,omitempty"`
+	// Extends is the name of the pack this one is based on, e.g.
+	// `go/service-base`. Its templates are loaded along with the ones of this
+	// pack, which override the templates with the same path.
+	Extends string `yaml:"extends,omitempty"`
+	// Required are the keys the rendering context must contain.
+	Required []string `yaml:"required,omitempty"`
+	// Defaults are the values of the keys missing from the rendering context.
+	Defaults map[string]interface{} `yaml:"defaults,omitempty"`
+	// Modes set the mode of the rendered files, the first matching one
+	// applying. The `!!mode` header of a template takes precedence.
+	Modes []PackMode `yaml:"modes,omitempty"`
+}
+
+// readPackManifest reads the manifest at the root of a pack file system.
+func readPackManifest(fsys fs.FS) (*PackManifest, error) {
+	manifest := &PackManifest{}
+	data, err := fs.ReadFile(fsys, PackManifestFileName)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return manifest, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = yaml.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("parsing pack manifest `%s`: %s", PackManifestFileName, err)
+	}
+
+	return manifest, nil
+}
+
+// Validate checks that the versions of the manifest are valid and that this
+// codegenerator is recent enough to render the pack.
+func (m *PackManifest) Validate() error {
+	if m.Version != "" {
+		if _, err := semver.NewVersion(m.Version); err != nil {
+			return fmt.Errorf("invalid pack version `%s`: %s", m.Version, err)
+		}
+	}
+
+	if m.MinCodegeneratorVersion != "" {
+		minVersion, err := semver.NewVersion(m.MinCodegeneratorVersion)
+
+		if err != nil {
+			return fmt.Errorf("invalid minimum codegenerator version `%s`: %s", m.MinCodegeneratorVersion, err)
+		}
+
+		if current := Version(); current != "" {
+			currentVersion, err := semver.NewVersion(current)
+
+			if err != nil {
+				return fmt.Errorf("invalid codegenerator version `%s`: %s", current, err)
+			}
+
+			if currentVersion.LessThan(minVersion) {
+				return fmt.Errorf("the pack requires codegenerator %s or later, this is %s", minVersion, current)
+			}
+		}
+	}
+
+	for key := range m.Defaults {
+		if containsString(m.Required, key) {
+			return fmt.Errorf("required key `%s` can't have a default value", key)
+		}
+	}
+
+	_, err := parsePackModes(m.Modes)
+
+	return err
+}
+
+// Context returns a copy of a rendering context completed with the default
+// values of the manifest. Plan, Check and Render already do so with the
+// manifests of the packs the templates were loaded from.
+//
+// Returns an error listing the required keys missing from the context.
+func (m *PackManifest) Context(ctx map[string]interface{}) (map[string]interface{}, error) {
+	var missing []string
+
+	for _, key := range m.Required {
+		if _, ok := ctx[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing required context key(s): %s", strings.Join(missing, ", "))
+	}
+
+	completed := make(map[string]interface{}, len(ctx)+len(m.Defaults))
+
+	for key, value := range m.Defaults {
+		completed[key] = value
+	}
+
+	for key, value := range ctx {
+		completed[key] = value
+	}
+
+	return completed, nil
+}
+
+// complete is like Context but accepts any rendering context, as Render does.
+//
+// Maps with string keys are copied and completed with the default values. The
+// required keys of other contexts, such as structs, must be fields or methods
+// and the default values don't apply.
+func (m *PackManifest) complete(ctx interface{}) (interface{}, error) {
+	if ctx == nil {
+		return m.Context(map[string]interface{}{})
+	}
+
+	if v := reflect.ValueOf(ctx); v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String {
+		values := make(map[string]interface{}, v.Len())
+
+		for iter := v.MapRange(); iter.Next(); {
+			values[iter.Key().String()] = iter.Value().Interface()
+		}
+
+		return m.Context(values)
+	}
+
+	var missing []string
+
+	for _, key := range m.Required {
+		if !hasContextField(ctx, key) {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing required context key(s): %s", strings.Join(missing, ", "))
+	}
+
+	return ctx, nil
+}
+
+// hasContextField reports whether a template can use `.name` with a context
+// which isn't a map: it must have an exported field or method with that name.
+func hasContextField(ctx interface{}, name string) bool {
+	v := reflect.ValueOf(ctx)
+
+	for v.IsValid() {
+		if v.MethodByName(name).IsValid() {
+			return true
+		}
+
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface:
+			if v.IsNil() {
+				return false
+			}
+			v = v.Elem()
+		case reflect.Struct:
+			field, ok := v.Type().FieldByName(name)
+			return ok && field.IsExported()
+		default:
+			return false
+		}
+	}
+
+	return false
+}
+
+// manifestTemplate is implemented by the templates loaded from a pack.
+type manifestTemplate interface {
+	getPackManifest() *PackManifest
+}
+
+// withPackManifest records the manifest of the pack a template was loaded from.
+func withPackManifest(t Template, manifest *PackManifest) Template {
+	if impl, ok := t.(templateImpl); ok && manifest != nil {
+		impl.manifest = manifest
+		return impl
+	}
+
+	return t
+}
+
+// applyPackManifests checks that a rendering context has the keys required by
+// the manifests of the packs of a list of templates, and completes it with
+// their default values, see PackManifest.complete.
+func applyPackManifests(templates []Template, ctx interface{}) (interface{}, error) {
+	applied := map[*PackManifest]bool{}
+
+	for _, tmpl := range templates {
+		t, ok := tmpl.(manifestTemplate)
+
+		if !ok {
+			continue
+		}
+
+		manifest := t.getPackManifest()
+
+		if manifest == nil || applied[manifest] || len(manifest.Required)+len(manifest.Defaults) == 0 {
+			continue
+		}
+
+		applied[manifest] = true
+
+		var err error
+
+		if ctx, err = manifest.complete(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return ctx, nil
+}
+
+// extend returns the manifest of a pack extending a pack with this manifest.
+// Required keys, defaults and modes are combined, the child taking precedence.
+func (m *PackManifest) extend(child *PackManifest) *PackManifest {
+	extended := *child
+	extended.Required = nil
+	extended.Defaults = map[string]interface{}{}
+	extended.Modes = append(append([]PackMode{}, child.Modes...), m.Modes...)
+
+	for key, value := range m.Defaults {
+		if !containsString(child.Required, key) {
+			extended.Defaults[key] = value
+		}
+	}
+
+	for key, value := range child.Defaults {
+		extended.Defaults[key] = value
+	}
+
+	for _, key := range append(append([]string{}, m.Required...), child.Required...) {
+		if _, ok := child.Defaults[key]; !ok && !containsString(extended.Required, key) {
+			extended.Required = append(extended.Required, key)
+		}
+	}
+
+	return &extended
+}
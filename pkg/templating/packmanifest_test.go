@@ -0,0 +1,109 @@
+package templating
+
+import (
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackManifest(t *testing.T) {
+	pp := NewPackProvider()
+	RegisterFSPackProviders(pp, []string{"fixtures/templates"})
+
+	pack, err := pp.Provide("inherit", "base")
+	require.NoError(t, err)
+	manifest, err := pack.GetManifest()
+	require.NoError(t, err)
+	assert.Equal(t, &PackManifest{
+		Description:             "Base of the inheritance fixtures",
+		Version:                 "1.2.0",
+		Authors:                 []string{"Jane Doe"},
+		MinCodegeneratorVersion: "0.1.0",
+		Required:                []string{"Name", "Owner"},
+		Defaults:                map[string]interface{}{"License": "MIT"},
+	}, manifest)
+
+	templates, err := pack.LoadTemplates()
+	require.NoError(t, err)
+	for _, template := range templates {
+		assert.NotEqual(t, PackManifestFileName, template.GetPath())
+	}
+
+	pack, err = pp.Provide("inherit", "middle")
+	require.NoError(t, err)
+	manifest, err = pack.GetManifest()
+	require.NoError(t, err)
+	assert.Equal(t, "Middle of the inheritance fixtures", manifest.Description)
+	assert.Equal(t, []string{"Name"}, manifest.Required)
+	assert.Equal(t, map[string]interface{}{"License": "MIT", "Owner": "ACME"}, manifest.Defaults)
+
+	_, err = manifest.Context(map[string]interface{}{})
+	assert.EqualError(t, err, "missing required context key(s): Name")
+
+	ctx, err := manifest.Context(map[string]interface{}{"Name": "world", "License": "Apache-2.0"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"Name": "world", "License": "Apache-2.0", "Owner": "ACME"}, ctx)
+}
+
+func TestPackManifestValidate(t *testing.T) {
+	defer func(v string) { version = v }(version)
+	version = "0.1.0"
+
+	for manifest, expected := range map[string]string{
+		"version: one":                         "pack `invalid`: invalid pack version `one`: Invalid Semantic Version",
+		"min-codegenerator-version: 99.0.0":    "pack `invalid`: the pack requires codegenerator 99.0.0 or later, this is 0.1.0",
+		"required: [A]\ndefaults:\n  A: value": "pack `invalid`: required key `A` can't have a default value",
+		"authors: 42":                          "pack `invalid`: parsing pack manifest `pack.yaml`: yaml: unmarshal errors:\n  line 1: cannot unmarshal !!int `42` into []string",
+	} {
+		pp := NewPackProvider()
+		require.NoError(t, pp.RegisterProvider(NewEmbededPackProvider(fstest.MapFS{
+			"invalid/pack.yaml": &fstest.MapFile{Data: []byte(manifest)},
+		})))
+
+		_, err := pp.Provide("", "invalid")
+		assert.EqualError(t, err, expected)
+	}
+}
+
+func TestPackManifestDevelopmentVersion(t *testing.T) {
+	// Tests are development builds, which satisfy any minimum version.
+	assert.Empty(t, Version())
+
+	manifest := &PackManifest{MinCodegeneratorVersion: "99.0.0"}
+	assert.NoError(t, manifest.Validate())
+}
+
+func TestRenderPackManifest(t *testing.T) {
+	pp := NewPackProvider()
+	require.NoError(t, pp.RegisterProvider(NewEmbededPackProvider(fstest.MapFS{
+		"params/pack.yaml":      &fstest.MapFile{Data: []byte("required: [Name]\ndefaults:\n  License: MIT\n")},
+		"params/a.txt.template": &fstest.MapFile{Data: []byte("{{ .Name }} {{ .License }}\n")},
+	})))
+	pack, err := pp.Provide("", "params")
+	require.NoError(t, err)
+	templates, err := pack.LoadTemplates()
+	require.NoError(t, err)
+
+	m := NewMemFS()
+	_, _, err = RenderFS(m, templates, "output", map[string]string{"License": "Apache-2.0"})
+	assert.EqualError(t, err, "missing required context key(s): Name")
+	_, _, err = RenderFS(m, templates, "output", struct{ License string }{"Apache-2.0"})
+	assert.EqualError(t, err, "missing required context key(s): Name")
+	assert.Empty(t, m.Paths())
+
+	_, _, err = RenderFS(m, templates, "output", map[string]string{"Name": "world"})
+	require.NoError(t, err)
+	data, err := m.ReadFile(filepath.Join("output", "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "world MIT\n", string(data))
+
+	m = NewMemFS()
+	_, _, err = RenderFS(m, templates, "output", struct{ Name, License string }{"struct", "Apache-2.0"})
+	require.NoError(t, err)
+	data, err = m.ReadFile(filepath.Join("output", "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "struct Apache-2.0\n", string(data))
+}
@@ -1,12 +1,69 @@
 package templating
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// errNoPackFound is returned by the group provider when none of its providers has a pack.
+var errNoPackFound = errors.New("no pack found")
 
 // Packprovider provides a template pack
 type PackProvider interface {
 	Provide(templateType, templateName string) (Pack, error)
 }
 
+// ContextPackProvider is a PackProvider which stops looking for a pack once a
+// context is done.
+type ContextPackProvider interface {
+	PackProvider
+	ProvideContext(ctx context.Context, templateType, templateName string) (Pack, error)
+}
+
+// ProvideContext provides a pack with the specified provider, aborting with
+// the error of the context once it is done.
+//
+// Providers which don't implement ContextPackProvider are only checked before
+// being called.
+func ProvideContext(ctx context.Context, provider PackProvider, templateType, templateName string) (Pack, error) {
+	if p, ok := provider.(ContextPackProvider); ok {
+		return p.ProvideContext(ctx, templateType, templateName)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return provider.Provide(templateType, templateName)
+}
+
+// A PackOption configures how the packs of a provider load their templates.
+type PackOption func(*packOptions)
+
+type packOptions struct {
+	headerDirectives *HeaderDirectiveRegistry
+}
+
+// WithPackHeaderDirectives sets the registry the custom header directives of
+// the templates are parsed with, HeaderDirectives by default.
+func WithPackHeaderDirectives(registry *HeaderDirectiveRegistry) PackOption {
+	return func(o *packOptions) { o.headerDirectives = registry }
+}
+
+func newPackOptions(options []PackOption) packOptions {
+	o := packOptions{headerDirectives: HeaderDirectives}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return o
+}
+
 // PackGroupProvider is a meta pack provider
 type PackGroupProvider interface {
 	RegisterProvider(provider PackProvider) error
@@ -22,13 +79,65 @@ func (p *packLoader) RegisterProvider(provider PackProvider) error {
 }
 
 func (p *packLoader) Provide(templateType, templateName string) (Pack, error) {
+	return p.ProvideContext(context.Background(), templateType, templateName)
+}
+
+// ProvideContext provides a pack with the first provider that has it.
+//
+// The manifest of the pack is validated. If it extends another pack, the base
+// pack is provided the same way, and so on, and a pack combining their
+// templates is returned.
+func (p *packLoader) ProvideContext(ctx context.Context, templateType, templateName string) (Pack, error) {
+	return p.provide(ctx, templateType, templateName, nil)
+}
+
+// provide provides a pack extended by the packs of the chain, if any.
+func (p *packLoader) provide(ctx context.Context, templateType, templateName string, chain []string) (Pack, error) {
+	var pack Pack
+
 	for _, provider := range p.providers {
-		pack, err := provider.Provide(templateType, templateName)
+		provided, err := ProvideContext(ctx, provider, templateType, templateName)
 		if err == nil {
-			return pack, err
+			pack = provided
+			break
 		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+	}
+
+	if pack == nil {
+		return nil, errNoPackFound
+	}
+
+	manifest, err := pack.GetManifest()
+
+	if err == nil {
+		err = manifest.Validate()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("pack `%s`: %s", pack.GetName(), err)
+	}
+
+	if manifest.Extends == "" {
+		return pack, nil
+	}
+
+	chain = append(chain, filepath.ToSlash(pack.GetName()))
+	base := path.Clean(manifest.Extends)
+
+	if containsString(chain, base) {
+		return nil, fmt.Errorf("pack inheritance cycle: %s -> %s", strings.Join(chain, " -> "), base)
 	}
-	return nil, errors.New("no pack found")
+
+	basePack, err := p.provide(ctx, "", base, chain)
+
+	if err != nil {
+		return nil, fmt.Errorf("resolving pack `%s` extended by `%s`: %s", base, pack.GetName(), err)
+	}
+
+	return &extendedPack{Pack: pack, base: basePack}, nil
 }
 
 func NewPackProvider() *packLoader {
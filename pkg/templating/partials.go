@@ -0,0 +1,122 @@
+package templating
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"text/template"
+	"text/template/parse"
+)
+
+// PartialsDir is the directory of a pack holding its partials.
+//
+// Partials are `.tmpl` files parsed once when the pack is loaded. The
+// templates they `{{ define }}` can be used by every template of the pack with
+// `{{ template "name" . }}`. Partials are never rendered as output files and
+// use the default delimiters. They can call the functions of the Renderer, as
+// templates do.
+const PartialsDir = "_partials"
+
+const partialExt = ".tmpl"
+
+// loadPartials parses the partials of a pack. Returns nil if the pack has none.
+//
+// The functions they call are only checked when they are rendered, with the
+// functions of the Renderer.
+func loadPartials(fsys fs.FS) (*template.Template, error) {
+	paths, err := fs.Glob(fsys, path.Join(PartialsDir, "*"+partialExt))
+
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+
+	partials := template.New("")
+
+	for _, p := range paths {
+		data, err := fs.ReadFile(fsys, p)
+
+		if err != nil {
+			return nil, err
+		}
+
+		trees := map[string]*parse.Tree{}
+		tree := parse.New(p)
+		tree.Mode = parse.SkipFuncCheck
+
+		if _, err = tree.Parse(string(data), "", "", trees); err != nil {
+			return nil, fmt.Errorf("parsing partial `%s`: %s", p, err)
+		}
+
+		for name, tree := range trees {
+			if _, err = partials.AddParseTree(name, tree); err != nil {
+				return nil, fmt.Errorf("parsing partial `%s`: %s", p, err)
+			}
+		}
+	}
+
+	return partials, nil
+}
+
+// partialsPack is implemented by the packs which can have partials.
+type partialsPack interface {
+	getPartials() (*template.Template, error)
+}
+
+// packPartials returns the partials of a pack, nil if it has none.
+func packPartials(pack Pack) (*template.Template, error) {
+	if p, ok := pack.(partialsPack); ok {
+		return p.getPartials()
+	}
+
+	return nil, nil
+}
+
+// mergePartials returns the partials of a base pack along with the ones of a
+// pack extending it, whose defines replace the base ones with the same name.
+func mergePartials(base, own *template.Template) (*template.Template, error) {
+	if base == nil {
+		return own, nil
+	}
+
+	if own == nil {
+		return base, nil
+	}
+
+	merged, err := base.Clone()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range own.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+
+		if _, err = merged.AddParseTree(t.Name(), t.Tree); err != nil {
+			return nil, err
+		}
+	}
+
+	return merged, nil
+}
+
+// withPartials makes the defines of the partials of a pack available to a template.
+func withPartials(t Template, partials *template.Template) Template {
+	if partials == nil {
+		return t
+	}
+
+	impl, ok := t.(templateImpl)
+
+	if !ok {
+		return t
+	}
+
+	if content, ok := impl.Content.(templatedTemplateContent); ok {
+		content.partials = partials
+		impl.Content = content
+	}
+
+	return impl
+}
@@ -0,0 +1,440 @@
+package templating
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"code.cestus.io/libs/codegenerator/pkg/placeholder"
+)
+
+// ActionKind describes what Render does with a single output file.
+type ActionKind string
+
+const (
+	// ActionCreate creates a file which does not exist yet.
+	ActionCreate ActionKind = "create"
+	// ActionUpdate overwrites an existing file with a different content.
+	ActionUpdate ActionKind = "update"
+	// ActionUnchanged leaves an existing file as is because its content is up to date.
+	ActionUnchanged ActionKind = "unchanged"
+	// ActionSkipExists leaves an existing file as is because of the `!!if-not-exists` header.
+	ActionSkipExists ActionKind = "skip-exists"
+	// ActionSkipCondition skips a file because its `!!if` or `!!ifor` condition is false.
+	ActionSkipCondition ActionKind = "skip-condition"
+	// ActionRemove removes a file because it rendered empty and has the `!!remove-if-empty` header.
+	ActionRemove ActionKind = "remove"
+	// ActionPrune removes an unmodified file which was generated by a previous
+	// Render but isn't produced anymore, see WithManifestFileName.
+	ActionPrune ActionKind = "prune"
+)
+
+// An Action is a single step of a render plan.
+type Action struct {
+	Kind ActionKind
+	// Template is the path of the template that produced the action.
+	Template string
+	// Pack is the name of the pack the template was loaded from.
+	Pack string
+	// Path is the path of the output file.
+	Path string
+	// Content is the data Render writes, after code regions were merged.
+	Content []byte
+	// Existing is the data currently on disk, nil if the file doesn't exist.
+	Existing []byte
+	// Regions are the identifiers of the code regions of the existing file
+	// which were replaced with the generated ones.
+	Regions []string
+	// Commands are the extra-rendering commands to execute for the file.
+	Commands [][]string
+	// Mode are the permission bits Render sets on the file, 0 to create it
+	// with 0666, before umask, and keep the ones of an existing file.
+	Mode fs.FileMode
+
+	// rendered is the output of the template, before post-processing.
+	rendered []byte
+	// commands render the extra-rendering commands of the templates
+	// producing the file.
+	commands       []func() ([][]string, error)
+	relPath        string
+	postProcessors []string
+	removeIfEmpty  bool
+	merge          MergeStrategy
+	// existingMode are the permission bits of the existing file.
+	existingMode fs.FileMode
+}
+
+// Plan computes the actions Render would perform for a list of templates,
+// without modifying anything on disk.
+//
+// Actions are returned in template order, followed by the pruning of the
+// orphaned files. Patterns have the same meaning as in Render.
+func Plan(templates []Template, root string, ctx interface{}, patterns ...string) ([]Action, error) {
+	return PlanFS(NewOSFS(), templates, root, ctx, patterns...)
+}
+
+// PlanFS is like Plan but reads the existing files from the specified file system.
+func PlanFS(fsys OutputFS, templates []Template, root string, ctx interface{}, patterns ...string) ([]Action, error) {
+	return defaultRenderer(fsys).Plan(templates, root, ctx, patterns...)
+}
+
+// Plan is like the package level Plan but uses the configuration of the renderer.
+func (r *Renderer) Plan(templates []Template, root string, ctx interface{}, patterns ...string) ([]Action, error) {
+	return r.PlanContext(context.Background(), templates, root, ctx, patterns...)
+}
+
+// PlanContext is like Plan but aborts with the error of the context once it
+// is done. The context is checked before each template, and passed to the
+// file system of the renderer if it is a ContextFS.
+//
+// Templates are rendered concurrently when the renderer was created
+// WithParallelism. The actions are returned in template order all the same.
+func (r *Renderer) PlanContext(ctx context.Context, templates []Template, root string, data interface{}, patterns ...string) ([]Action, error) {
+	actions, _, err := r.withContext(ctx).plan(ctx, templates, root, data, patterns)
+	return actions, err
+}
+
+// plan computes the actions for a list of templates as well as the
+// manifest to record once they are applied.
+func (r *Renderer) plan(ctx context.Context, templates []Template, root string, data interface{}, patterns []string) (actions []Action, manifest *Manifest, err error) {
+	if data, err = applyPackManifests(templates, data); err != nil {
+		return nil, nil, err
+	}
+
+	if err = validateContext(templates, data); err != nil {
+		return nil, nil, err
+	}
+
+	planned, err := r.planTemplates(ctx, templates, root, data, patterns)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, templateActions := range planned {
+		for _, action := range templateActions {
+			if action != nil {
+				actions = append(actions, *action)
+			}
+		}
+	}
+
+	if actions, err = r.mergeCollisions(actions); err != nil {
+		return nil, nil, err
+	}
+
+	for i := range actions {
+		if err = actions[i].renderCommands(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if r.manifestFileName == "" {
+		return actions, nil, nil
+	}
+
+	var previous *Manifest
+
+	if previous, err = r.ReadManifest(root); err != nil {
+		return nil, nil, err
+	}
+
+	return r.updateManifest(previous, root, templates, actions, patterns)
+}
+
+// planTemplates computes the actions of every template, using up to
+// r.parallelism workers. Actions are returned in template order.
+//
+// When several templates fail, the error of the first one in template order is
+// returned, so that the outcome doesn't depend on the scheduling.
+func (r *Renderer) planTemplates(ctx context.Context, templates []Template, root string, data interface{}, patterns []string) ([][]*Action, error) {
+	actions := make([][]*Action, len(templates))
+	errs := make([]error, len(templates))
+	workers := r.parallelism
+
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(templates) {
+		workers = len(templates)
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	// Templates after the first failed one don't need to be rendered.
+	firstFailed := len(templates)
+	next := make(chan int)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range next {
+				mutex.Lock()
+				skip := i > firstFailed
+				mutex.Unlock()
+
+				if skip {
+					continue
+				}
+
+				if errs[i] = ctx.Err(); errs[i] == nil {
+					actions[i], errs[i] = r.planTemplateItems(templates[i], root, data, patterns)
+				}
+
+				if errs[i] != nil {
+					mutex.Lock()
+					if i < firstFailed {
+						firstFailed = i
+					}
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range templates {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return actions, nil
+}
+
+// matchPatterns reports whether a relative path matches at least one of the
+// patterns. An empty list of patterns matches everything.
+func matchPatterns(patterns []string, relPath string) (bool, error) {
+	if len(patterns) == 0 {
+		return true, nil
+	}
+
+	for _, pattern := range patterns {
+		if ok, err := filepath.Match(pattern, relPath); err != nil {
+			return false, fmt.Errorf("matching pattern `%s`: %s", pattern, err)
+		} else if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// planTemplateItems computes the actions for a single template, once per item
+// if it has a `!!foreach` header.
+func (r *Renderer) planTemplateItems(tmpl Template, root string, ctx interface{}, patterns []string) ([]*Action, error) {
+	if tmpl.GetHeader().Foreach == nil {
+		action, err := r.planTemplate(tmpl, root, ctx, patterns)
+		return []*Action{action}, err
+	}
+
+	items, err := tmpl.GetHeader().evaluateForeach(ctx, r.funcs)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate header `foreach` in `%s`: %s", tmpl.GetPath(), err)
+	}
+
+	itemContexts, err := foreachContexts(items, ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate header `foreach` in `%s`: %s", tmpl.GetPath(), err)
+	}
+
+	actions := make([]*Action, len(itemContexts))
+
+	for i, itemContext := range itemContexts {
+		if actions[i], err = r.planTemplate(tmpl, root, itemContext, patterns); err != nil {
+			return nil, err
+		}
+	}
+
+	return actions, nil
+}
+
+// planTemplate computes the action for a single template. It returns a nil
+// action if the template does not match any of the patterns.
+func (r *Renderer) planTemplate(tmpl Template, root string, ctx interface{}, patterns []string) (*Action, error) {
+	strict := r.strict || tmpl.GetHeader().Strict
+	relPath, err := renderName(tmpl.GetName(), ctx, r.funcs, strict)
+
+	if err != nil {
+		return nil, fmt.Errorf("rendering name for template `%s`: %s", tmpl.GetPath(), err)
+	}
+
+	if matched, err := matchPatterns(patterns, relPath); err != nil {
+		return nil, err
+	} else if !matched {
+		// None of the patterns matched. Skip the file.
+		return nil, nil
+	}
+
+	path := filepath.Join(root, relPath)
+	action := &Action{
+		Template: tmpl.GetPath(),
+		Pack:     templatePack(tmpl),
+		Path:     path,
+	}
+
+	if action.Existing, err = r.fsys.ReadFile(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if action.Existing != nil {
+		info, err := r.fsys.Stat(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		action.existingMode = info.Mode().Perm()
+	}
+
+	if ok, keyword, err := tmpl.GetHeader().evaluateIf(ctx, r.funcs); err != nil {
+		return nil, fmt.Errorf("failed to evaluate header `%s` condition in `%s`: %s", keyword, tmpl.GetPath(), err)
+	} else if !ok {
+		action.Kind = ActionSkipCondition
+		return action, nil
+	}
+
+	if ok, keyword, err := r.headerDirectives.runHooks(ctx, *action, tmpl.GetHeader()); err != nil {
+		return nil, fmt.Errorf("running header `%s` hook in `%s`: %s", keyword, tmpl.GetPath(), err)
+	} else if !ok {
+		action.Kind = ActionSkipCondition
+		return action, nil
+	}
+
+	// If the generated file already exists and IfNotExists is specified,
+	// don't overwrite it.
+	if action.Existing != nil && tmpl.GetHeader().IfNotExists {
+		action.Kind = ActionSkipExists
+		return action, nil
+	}
+
+	output := &bytes.Buffer{}
+
+	if err = renderContent(tmpl.GetContent(), output, ctx, r.funcs, strict); err != nil {
+		return nil, fmt.Errorf("rendering content for template `%s`: %s", tmpl.GetPath(), err)
+	}
+
+	action.rendered = output.Bytes()
+	action.relPath = relPath
+	action.postProcessors = postProcessorNames(r.postProcessors, r.formatGoSource, tmpl.GetHeader(), relPath)
+	action.removeIfEmpty = tmpl.GetHeader().RemoveIfEmpty
+	action.merge = tmpl.GetHeader().Merge
+	action.Mode = fileMode(tmpl, relPath)
+
+	postProcessors := action.postProcessors
+	action.commands = []func() ([][]string, error){func() ([][]string, error) {
+		commands, err := renderGeneratorCommands(tmpl, ctx, r.funcs)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if p, _ := filepath.Rel(root, path); strings.HasSuffix(p, ".go") {
+			// The go post-processor already fixes the imports.
+			if !containsString(postProcessors, PostProcessorGo) {
+				commands = append(commands, []string{"goimports", "-l", "-w", "./" + p})
+			}
+			if !tmpl.GetHeader().NoGoGenerate {
+				commands = append(commands, []string{"go", "generate", "./" + p})
+			}
+		}
+
+		return commands, nil
+	}}
+
+	if err = r.completeAction(action); err != nil {
+		return nil, err
+	}
+
+	return action, nil
+}
+
+// completeAction post-processes the rendered content of an action, merges it
+// with the existing file and decides what to do with the file.
+func (r *Renderer) completeAction(action *Action) error {
+	processed, err := postProcess(r.postProcessors, action.postProcessors, action.Path, action.rendered)
+
+	if err != nil {
+		return fmt.Errorf("post-processing `%s` rendered from template `%s`: %s", action.relPath, action.Template, err)
+	}
+
+	action.Content = processed
+	action.Regions = nil
+
+	// If the file already exists, we replace the placeholders in the
+	// initial files with the generated ones and reuse that file instead.
+	if action.Existing != nil {
+		placeholders := r.marks.FindAll(processed)
+		action.Content = r.marks.ReplaceAll(action.Existing, placeholders)
+		action.Regions = r.mergedRegions(action.Existing, placeholders)
+	}
+
+	switch {
+	case len(action.Content) == 0 && action.removeIfEmpty:
+		action.Kind = ActionRemove
+	case action.Existing == nil:
+		action.Kind = ActionCreate
+	case bytes.Equal(action.Existing, action.Content) && (action.Mode == 0 || action.Mode == action.existingMode):
+		action.Kind = ActionUnchanged
+	default:
+		action.Kind = ActionUpdate
+	}
+
+	return nil
+}
+
+// renderCommands renders the extra-rendering commands of an action, once it is
+// known to produce a file. Files which are removed or skipped have none.
+func (a *Action) renderCommands() error {
+	commands := a.commands
+	a.commands = nil
+
+	switch a.Kind {
+	case ActionCreate, ActionUpdate, ActionUnchanged:
+	default:
+		return nil
+	}
+
+	for _, render := range commands {
+		rendered, err := render()
+
+		if err != nil {
+			return err
+		}
+
+		a.Commands = append(a.Commands, rendered...)
+	}
+
+	return nil
+}
+
+// mergedRegions returns the identifiers of the placeholders found in data.
+func (r *Renderer) mergedRegions(data []byte, placeholders []placeholder.Placeholder) (regions []string) {
+	existing := map[string]bool{}
+
+	for _, p := range r.marks.FindAll(data) {
+		existing[p.Identifier] = true
+	}
+
+	for _, p := range placeholders {
+		if existing[p.Identifier] {
+			regions = append(regions, p.Identifier)
+		}
+	}
+
+	return
+}
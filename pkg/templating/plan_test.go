@@ -0,0 +1,145 @@
+package templating
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadFixturePack(t *testing.T, packName string) []Template {
+	t.Helper()
+
+	pp := NewPackProvider()
+	RegisterFSPackProviders(pp, []string{"fixtures/templates"})
+	pack, err := pp.Provide("", packName)
+	require.NoError(t, err)
+
+	templates, err := pack.LoadTemplates()
+	require.NoError(t, err)
+
+	return templates
+}
+
+func TestPlan(t *testing.T) {
+	templates := loadFixturePack(t, "plan")
+	outputPath := t.TempDir()
+	ctx := map[string]interface{}{"Name": "world", "Enabled": false}
+
+	existing := map[string]string{
+		"updated.txt":   "Hello\n// region CODE_REGION(Name)\nnobody\n// endregion\n",
+		"unchanged.txt": "Static content\n",
+		"exists.txt":    "Keep me\n",
+	}
+	for name, content := range existing {
+		require.NoError(t, os.WriteFile(filepath.Join(outputPath, name), []byte(content), 0666))
+	}
+
+	actions, err := Plan(templates, outputPath, ctx)
+	require.NoError(t, err)
+
+	byName := map[string]Action{}
+	for _, action := range actions {
+		rel, err := filepath.Rel(outputPath, action.Path)
+		require.NoError(t, err)
+		byName[rel] = action
+	}
+	require.Len(t, byName, 6)
+
+	assert.Equal(t, ActionCreate, byName["created.txt"].Kind)
+	assert.Equal(t, "Hello world\n", string(byName["created.txt"].Content))
+	assert.Nil(t, byName["created.txt"].Existing)
+
+	assert.Equal(t, ActionUpdate, byName["updated.txt"].Kind)
+	assert.Equal(t, "Hello\n// region CODE_REGION(Name)\nworld\n// endregion\n", string(byName["updated.txt"].Content))
+	assert.Equal(t, existing["updated.txt"], string(byName["updated.txt"].Existing))
+
+	assert.Equal(t, ActionUnchanged, byName["unchanged.txt"].Kind)
+	assert.Equal(t, ActionSkipExists, byName["exists.txt"].Kind)
+	assert.Equal(t, "Keep me\n", string(byName["exists.txt"].Existing))
+	assert.Equal(t, ActionSkipCondition, byName["skipped.txt"].Kind)
+	assert.Equal(t, "skipped.txt.template", byName["skipped.txt"].Template)
+	assert.Equal(t, ActionRemove, byName["removed.txt"].Kind)
+
+	// Planning must not touch the output directory.
+	_, err = os.Stat(filepath.Join(outputPath, "created.txt"))
+	assert.True(t, os.IsNotExist(err))
+	data, err := os.ReadFile(filepath.Join(outputPath, "updated.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, existing["updated.txt"], string(data))
+}
+
+func TestPlanInvalidContent(t *testing.T) {
+	templates := loadFixturePack(t, "invalid-content")
+
+	actions, err := Plan(templates, t.TempDir(), "world")
+	assert.Error(t, err)
+	assert.Nil(t, actions)
+}
+
+func TestPlanThenRender(t *testing.T) {
+	templates := loadFixturePack(t, "plan")
+	outputPath := t.TempDir()
+	ctx := map[string]interface{}{"Name": "world", "Enabled": false}
+
+	_, err := Plan(templates, outputPath, ctx)
+	require.NoError(t, err)
+
+	// Planning must not consume the template sources.
+	_, _, err = Render(templates, outputPath, ctx)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(outputPath, "created.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world\n", string(data))
+}
+
+func TestPlanParallel(t *testing.T) {
+	var templates []Template
+	for i := 0; i < 50; i++ {
+		tmpl, err := LoadTemplate(fmt.Sprintf("file%02d.go.template", i), bytes.NewBufferString("package p\n\nconst N = {{ . }}\n"))
+		require.NoError(t, err)
+		templates = append(templates, tmpl)
+	}
+
+	m := NewMemFS()
+	sequential, err := NewRenderer(WithOutputFS(m)).Plan(templates, "output", 42)
+	require.NoError(t, err)
+
+	parallel, err := NewRenderer(WithOutputFS(m), WithParallelism(8)).Plan(templates, "output", 42)
+	require.NoError(t, err)
+	assert.Equal(t, sequential, parallel)
+
+	// The error of the first failing template is reported, whatever the scheduling.
+	for _, i := range []int{7, 31} {
+		tmpl, err := LoadTemplate(fmt.Sprintf("broken%02d.txt.template", i), bytes.NewBufferString("{{ .Missing.Field }}"))
+		require.NoError(t, err)
+		templates[i] = tmpl
+	}
+
+	for n := 0; n < 10; n++ {
+		_, err = NewRenderer(WithOutputFS(m), WithParallelism(8)).Plan(templates, "output", 42)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "broken07.txt.template")
+	}
+}
+
+func TestPlanCommandsOfRemovedFiles(t *testing.T) {
+	templates := []Template{
+		loadTemplateString(t, "empty.txt.template", "!!remove-if-empty\n!!generator-command echo {{ .Missing.Name }}\n"),
+		loadTemplateString(t, "full.txt.template", "!!generator-command echo {{ . }}\nfull\n"),
+	}
+
+	actions, err := NewRenderer(WithOutputFS(NewMemFS())).Plan(templates, "output", "world")
+	require.NoError(t, err)
+	require.Len(t, actions, 2)
+
+	assert.Equal(t, ActionRemove, actions[0].Kind)
+	assert.Nil(t, actions[0].Commands)
+	assert.Equal(t, ActionCreate, actions[1].Kind)
+	assert.Equal(t, [][]string{{"echo", "world"}}, actions[1].Commands)
+}
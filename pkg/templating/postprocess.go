@@ -0,0 +1,264 @@
+package templating
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A PostProcessor transforms the rendered content of a file, before code
+// regions are merged and the file is written.
+type PostProcessor interface {
+	Process(path string, data []byte) ([]byte, error)
+}
+
+// PostProcessorFunc is a function implementing PostProcessor.
+type PostProcessorFunc func(path string, data []byte) ([]byte, error)
+
+func (f PostProcessorFunc) Process(path string, data []byte) ([]byte, error) { return f(path, data) }
+
+// Names of the built-in post-processors.
+const (
+	PostProcessorGo              = "go"
+	PostProcessorJSON            = "json"
+	PostProcessorYAML            = "yaml"
+	PostProcessorTrailingNewline = "trailing-newline"
+)
+
+// postProcessNone is the value of the `!!postprocess` header disabling post-processing.
+const postProcessNone = "none"
+
+type postProcessorEntry struct {
+	name      string
+	processor PostProcessor
+	patterns  []string
+}
+
+// PostProcessorRegistry holds named post-processors and the files they apply to.
+//
+// It is safe for concurrent use.
+type PostProcessorRegistry struct {
+	mutex   sync.RWMutex
+	entries []postProcessorEntry
+}
+
+// NewPostProcessorRegistry creates a registry holding the built-in
+// post-processors. None of them applies to any file by default.
+func NewPostProcessorRegistry() *PostProcessorRegistry {
+	r := &PostProcessorRegistry{}
+	r.Register(PostProcessorGo, PostProcessorFunc(FormatGo))
+	r.Register(PostProcessorJSON, PostProcessorFunc(NormalizeJSON))
+	r.Register(PostProcessorYAML, PostProcessorFunc(NormalizeYAML))
+	r.Register(PostProcessorTrailingNewline, PostProcessorFunc(NormalizeTrailingNewline))
+
+	return r
+}
+
+// Register adds a named post-processor applying to the files matching any of
+// the patterns, replacing any post-processor previously registered with the
+// same name.
+//
+// A pattern is either an extension such as `.json`, or a glob matched with
+// filepath.Match against the base name of the file, or against its path
+// relative to the output root if it contains a path separator.
+func (r *PostProcessorRegistry) Register(name string, processor PostProcessor, patterns ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	entry := postProcessorEntry{name: name, processor: processor, patterns: patterns}
+
+	for i := range r.entries {
+		if r.entries[i].name == name {
+			r.entries[i] = entry
+			return
+		}
+	}
+
+	r.entries = append(r.entries, entry)
+}
+
+// Apply makes a registered post-processor apply to the files matching the
+// specified patterns, in addition to the ones it already applies to.
+func (r *PostProcessorRegistry) Apply(name string, patterns ...string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.entries {
+		if r.entries[i].name == name {
+			r.entries[i].patterns = append(r.entries[i].patterns, patterns...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown post-processor `%s`", name)
+}
+
+// Lookup returns the post-processor registered with the specified name.
+func (r *PostProcessorRegistry) Lookup(name string) (PostProcessor, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, entry := range r.entries {
+		if entry.name == name {
+			return entry.processor, true
+		}
+	}
+
+	return nil, false
+}
+
+// Match returns the names of the post-processors applying to a file, in
+// registration order.
+func (r *PostProcessorRegistry) Match(relPath string) (names []string) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, entry := range r.entries {
+		for _, pattern := range entry.patterns {
+			if matchPostProcessorPattern(pattern, relPath) {
+				names = append(names, entry.name)
+				break
+			}
+		}
+	}
+
+	return
+}
+
+func matchPostProcessorPattern(pattern string, relPath string) bool {
+	if strings.HasPrefix(pattern, ".") && !strings.ContainsAny(pattern, `*?[\/`) {
+		return filepath.Ext(relPath) == pattern
+	}
+
+	name := filepath.Base(relPath)
+	if strings.ContainsRune(pattern, '/') || strings.ContainsRune(pattern, filepath.Separator) {
+		name = relPath
+	}
+
+	ok, _ := filepath.Match(filepath.FromSlash(pattern), name)
+
+	return ok
+}
+
+// postProcessorNames returns the names of the post-processors to apply to a
+// file, as chosen by its header or by the registry.
+func postProcessorNames(registry *PostProcessorRegistry, formatGoSource bool, header Header, relPath string) []string {
+	if len(header.PostProcessors) > 0 {
+		if header.PostProcessors[0] == postProcessNone {
+			return nil
+		}
+		return header.PostProcessors
+	}
+
+	names := registry.Match(relPath)
+
+	// WithFormatGoSource is a shortcut for applying the go post-processor to all the Go files.
+	if formatGoSource && filepath.Ext(relPath) == ".go" && !containsString(names, PostProcessorGo) {
+		names = append([]string{PostProcessorGo}, names...)
+	}
+
+	return names
+}
+
+// postProcess applies a list of post-processors to the rendered content of a file.
+func postProcess(registry *PostProcessorRegistry, names []string, path string, data []byte) ([]byte, error) {
+	// Empty files are left empty, so that `!!remove-if-empty` keeps working.
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	for _, name := range names {
+		processor, ok := registry.Lookup(name)
+
+		if !ok {
+			return nil, fmt.Errorf("unknown post-processor `%s`", name)
+		}
+
+		var err error
+
+		if data, err = processor.Process(path, data); err != nil {
+			return nil, fmt.Errorf("post-processor `%s`: %s", name, err)
+		}
+	}
+
+	return data, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NormalizeJSON indents a JSON document with two spaces and sorts the keys of its objects.
+func NormalizeJSON(path string, data []byte) ([]byte, error) {
+	var value interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the JSON document")
+	}
+
+	output := &bytes.Buffer{}
+	encoder := json.NewEncoder(output)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return output.Bytes(), nil
+}
+
+// NormalizeYAML re-indents all the documents of a YAML stream with two spaces,
+// keeping their comments and the order of their keys.
+func NormalizeYAML(path string, data []byte) ([]byte, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	output := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(output)
+	encoder.SetIndent(2)
+
+	for {
+		var node yaml.Node
+
+		if err := decoder.Decode(&node); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := encoder.Encode(&node); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return output.Bytes(), nil
+}
+
+// NormalizeTrailingNewline makes a file end with exactly one new line.
+func NormalizeTrailingNewline(path string, data []byte) ([]byte, error) {
+	data = bytes.TrimRight(data, "\r\n")
+
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,93 @@
+package templating
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostProcessorRegistryMatch(t *testing.T) {
+	r := NewPostProcessorRegistry()
+	upper := PostProcessorFunc(func(path string, data []byte) ([]byte, error) { return bytes.ToUpper(data), nil })
+
+	r.Register("upper", upper, "*.md", "docs/*.txt")
+	require.NoError(t, r.Apply(PostProcessorJSON, ".json"))
+	assert.Error(t, r.Apply("unknown", ".json"))
+
+	assert.Equal(t, []string{"upper"}, r.Match(filepath.Join("sub", "README.md")))
+	assert.Equal(t, []string{"upper"}, r.Match(filepath.Join("docs", "a.txt")))
+	assert.Empty(t, r.Match(filepath.Join("other", "a.txt")))
+	assert.Equal(t, []string{PostProcessorJSON}, r.Match("a.json"))
+	assert.Empty(t, r.Match("a.jsonc"))
+
+	processor, ok := r.Lookup("upper")
+	require.True(t, ok)
+	data, err := processor.Process("a.md", []byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO", string(data))
+}
+
+func TestNormalizeJSON(t *testing.T) {
+	data, err := NormalizeJSON("a.json", []byte(`{"b": 1.50, "a": {"d": "<x>", "c": [1,2]}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"a\": {\n    \"c\": [\n      1,\n      2\n    ],\n    \"d\": \"<x>\"\n  },\n  \"b\": 1.50\n}\n", string(data))
+
+	_, err = NormalizeJSON("a.json", []byte(`{"a": 1} {}`))
+	assert.Error(t, err)
+}
+
+func TestNormalizeYAML(t *testing.T) {
+	data, err := NormalizeYAML("a.yaml", []byte("b:\n    # comment\n    c: 1\na:\n      - x\n---\nz: 2\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "b:\n  # comment\n  c: 1\na:\n  - x\n---\nz: 2\n", string(data))
+}
+
+func TestNormalizeTrailingNewline(t *testing.T) {
+	data, err := NormalizeTrailingNewline("a.md", []byte("# Title\n\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "# Title\n", string(data))
+}
+
+func TestRenderPostProcessors(t *testing.T) {
+	registry := NewPostProcessorRegistry()
+	require.NoError(t, registry.Apply(PostProcessorJSON, ".json"))
+
+	load := func(path, content string) Template {
+		tmpl, err := LoadTemplate(path, strings.NewReader(content))
+		require.NoError(t, err)
+		return tmpl
+	}
+
+	templates := []Template{
+		load("default.json.template", `{"b": {{ . }}, "a": 0}`),
+		load("optout.json.template", "!!postprocess none\n"+`{"b": {{ . }}, "a": 0}`),
+		load("chosen.md.template", "!!postprocess trailing-newline\n# {{ . }}\n\n\n"),
+		load("empty.json.template", "!!remove-if-empty\n"),
+	}
+
+	m := NewMemFS()
+	r := NewRenderer(WithOutputFS(m), WithPostProcessors(registry))
+	_, err := r.Render(templates, "output", 1)
+	require.NoError(t, err)
+
+	for name, expected := range map[string]string{
+		"default.json": "{\n  \"a\": 0,\n  \"b\": 1\n}\n",
+		"optout.json":  `{"b": 1, "a": 0}`,
+		"chosen.md":    "# 1\n",
+	} {
+		data, err := m.ReadFile(filepath.Join("output", name))
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(data), name)
+	}
+
+	_, err = r.Render([]Template{load("bad.json.template", "!!postprocess unknown\n{}")}, "output", 1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown post-processor `unknown`")
+
+	_, err = ParseHeaders(strings.NewReader("!!postprocess none json\n"), &Header{})
+	assert.Error(t, err)
+}
@@ -2,13 +2,9 @@ package templating
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"os"
 	"path/filepath"
-	"sort"
-	"strings"
-
-	"code.cestus.io/libs/codegenerator/pkg/placeholder"
 )
 
 // Render a list of templates to the specified directory.
@@ -17,113 +13,123 @@ import (
 // extra-rendering commands to execute..
 //
 // If patterns is specified, only the files that match at least one of the specified patterns will be rendered.
+//
+// The generated files are recorded in a manifest, see DefaultManifestFileName. Files
+// listed in the manifest of a previous call but not produced anymore are removed,
+// unless they were modified since.
 func Render(templates []Template, root string, ctx interface{}, patterns ...string) (generated []string, cmds [][]string, err error) {
-	for _, tmpl := range templates {
-		var relPath string
+	return RenderFS(NewOSFS(), templates, root, ctx, patterns...)
+}
 
-		if relPath, err = tmpl.GetName().Render(ctx); err != nil {
-			err = fmt.Errorf("rendering name for template `%s`: %s", tmpl.GetPath(), err)
-			return
-		}
+// RenderFS is like Render but reads and writes files through the specified file system.
+func RenderFS(fsys OutputFS, templates []Template, root string, ctx interface{}, patterns ...string) (generated []string, cmds [][]string, err error) {
+	result, err := RenderFSWithResult(fsys, templates, root, ctx, patterns...)
 
-		if len(patterns) > 0 {
-			matched := false
+	if err != nil {
+		return nil, nil, err
+	}
 
-			for _, pattern := range patterns {
-				if ok, err := filepath.Match(pattern, relPath); err != nil {
-					return nil, nil, fmt.Errorf("matching pattern `%s`: %s", pattern, err)
-				} else if ok {
-					matched = true
-					break
-				}
-			}
+	return result.Generated(), result.Commands(), nil
+}
 
-			if !matched {
-				// None of the patterns matched. Skip the file.
-				continue
-			}
-		}
+// RenderWithResult is like Render but describes the outcome for every file.
+func RenderWithResult(templates []Template, root string, ctx interface{}, patterns ...string) (*RenderResult, error) {
+	return RenderFSWithResult(NewOSFS(), templates, root, ctx, patterns...)
+}
 
-		if tmpl.GetHeader().If != nil {
-			if ok, err := tmpl.GetHeader().If(ctx); err != nil {
-				return nil, nil, fmt.Errorf("failed to evaluate header `if` condition in `%s`: %s", tmpl.GetPath(), err)
-			} else if !ok {
-				continue
-			}
-		}
+// RenderFSWithResult is like RenderWithResult but reads and writes files
+// through the specified file system.
+//
+// All the templates are rendered before anything is written. The files are
+// then replaced atomically, one by one, and if any of them fails, all the
+// changes applied so far are reverted.
+func RenderFSWithResult(fsys OutputFS, templates []Template, root string, ctx interface{}, patterns ...string) (result *RenderResult, err error) {
+	return defaultRenderer(fsys).Render(templates, root, ctx, patterns...)
+}
 
-		if tmpl.GetHeader().IfOr != nil {
-			if ok, err := tmpl.GetHeader().IfOr(ctx); err != nil {
-				return nil, nil, fmt.Errorf("failed to evaluate header `ifor` condition in `%s`: %s", tmpl.GetPath(), err)
-			} else if !ok {
-				continue
-			}
-		}
+// RenderContext is like Render but aborts once the context is done. The
+// context is checked before each template is rendered and before each file is
+// written, in which case the changes applied so far are reverted. It is also
+// passed to the file system if it is a ContextFS.
+//
+// The extra-rendering commands can be run with the same context with
+// Renderer.Execute.
+func RenderContext(ctx context.Context, templates []Template, root string, data interface{}, patterns ...string) (generated []string, cmds [][]string, err error) {
+	result, err := defaultRenderer(NewOSFS()).RenderContext(ctx, templates, root, data, patterns...)
 
-		path := filepath.Join(root, relPath)
-		dirPath := filepath.ToSlash(filepath.Dir(path))
+	if err != nil {
+		return nil, nil, err
+	}
 
-		if err = os.MkdirAll(dirPath, 0755); err != nil {
-			return
-		}
+	return result.Generated(), result.Commands(), nil
+}
 
-		var placeholders []placeholder.Placeholder
+// Render renders a list of templates to the specified directory of the file
+// system of the renderer, like RenderFSWithResult.
+func (r *Renderer) Render(templates []Template, root string, ctx interface{}, patterns ...string) (result *RenderResult, err error) {
+	return r.RenderContext(context.Background(), templates, root, ctx, patterns...)
+}
 
-		var existingData []byte
+// RenderContext is like Render but aborts once the context is done, see the
+// package level RenderContext.
+func (r *Renderer) RenderContext(ctx context.Context, templates []Template, root string, data interface{}, patterns ...string) (result *RenderResult, err error) {
+	var actions []Action
+	var manifest *Manifest
 
-		if existingData, err = os.ReadFile(path); err != nil && !os.IsNotExist(err) {
-			return
-		} else if err == nil {
-			// If the generated file already exists and IfNotExists is
-			// specified, don't overwrite it.
-			if tmpl.GetHeader().IfNotExists {
-				generated = append(generated, path)
-				continue
+	if actions, manifest, err = r.withContext(ctx).plan(ctx, templates, root, data, patterns); err != nil {
+		return nil, err
+	}
+
+	tx := newTransaction(withContext(r.fsys, ctx))
+	defer func() {
+		if err != nil {
+			// The changes are reverted even if the context is done.
+			tx.fsys = withContext(r.fsys, context.WithoutCancel(ctx))
+			if rollbackErr := tx.rollback(); rollbackErr != nil {
+				err = fmt.Errorf("%s (%s)", err, rollbackErr)
 			}
+			result = nil
 		}
+	}()
 
-		output := &bytes.Buffer{}
+	result = &RenderResult{}
 
-		if err = tmpl.GetContent().Render(output, ctx); err != nil {
-			err = fmt.Errorf("rendering content for template `%s`: %s", tmpl.GetPath(), err)
+	for _, action := range actions {
+		if err = ctx.Err(); err != nil {
 			return
 		}
 
-		// If the file already exists, we replace the placeholders in the
-		// initial files with the generated ones and reuse that file instead.
-		if existingData != nil {
-			placeholders = placeholder.FindAll(output.Bytes())
-			output = bytes.NewBuffer(placeholder.ReplaceAll(existingData, placeholders))
+		switch action.Kind {
+		case ActionCreate, ActionUpdate:
+			err = tx.replace(action.Path, action.Content, action.Mode)
+		case ActionRemove, ActionPrune:
+			err = tx.remove(action.Path)
 		}
 
-		if output.Len() == 0 && tmpl.GetHeader().RemoveIfEmpty {
-			os.Remove(path)
-			continue
-		}
-		if err = os.WriteFile(path, output.Bytes(), 0666); err != nil {
+		if err != nil {
 			return
 		}
-		generated = append(generated, path)
 
-		var generatorCmds [][]string
+		result.Files = append(result.Files, newFileResult(action))
+	}
 
-		generatorCmds, err = tmpl.RenderGeneratorCommands(ctx)
+	if manifest != nil {
+		err = writeManifest(tx, filepath.Join(root, r.manifestFileName), manifest)
+	}
 
-		if err != nil {
-			err = fmt.Errorf("in template %s: %s", tmpl.GetName(), err)
-			return
-		}
+	return
+}
 
-		cmds = append(cmds, generatorCmds...)
-		p, _ := filepath.Rel(root, path)
-		if strings.HasSuffix(p, ".go") {
-			cmds = append(cmds, []string{"goimports", "-l", "-w", "./" + p})
-			if !tmpl.GetHeader().NoGoGenerate {
-				cmds = append(cmds, []string{"go", "generate", "./" + p})
-			}
-		}
+func writeManifest(tx *transaction, path string, manifest *Manifest) error {
+	data, err := manifest.marshal()
+
+	if err != nil {
+		return err
 	}
-	sort.Strings(generated)
 
-	return
+	if existing, err := tx.fsys.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	return tx.replace(path, data, 0)
 }
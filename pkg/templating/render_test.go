@@ -15,6 +15,10 @@ func compareFolders(t *testing.T, referenceRootPath string, rootPath string) {
 	t.Helper()
 
 	filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if info.Name() == DefaultManifestFileName {
+			return nil
+		}
+
 		t.Run(path, func(t *testing.T) {
 			relPath, _ := filepath.Rel(rootPath, path)
 			referencePath := filepath.Join(referenceRootPath, relPath)
@@ -0,0 +1,237 @@
+package templating
+
+import (
+	"context"
+	"text/template"
+
+	sprig "github.com/Masterminds/sprig/v3"
+
+	"code.cestus.io/libs/codegenerator/pkg/executor"
+	"code.cestus.io/libs/codegenerator/pkg/placeholder"
+)
+
+// DefaultManifestFileName is the name of the file, relative to the output
+// root, in which Render records the files it generated.
+const DefaultManifestFileName = ".codegenerator-manifest.yaml"
+
+// A Renderer renders templates with its own configuration.
+//
+// Unlike the package level functions, which depend on package level variables
+// such as CodeGeneratorName or GoNamePrefixFunc, several renderers with
+// different settings can be used concurrently. A Renderer must not be modified
+// once created.
+type Renderer struct {
+	generatorName    string
+	names            *NameConverter
+	prefixFunc       func(string) string
+	initialisms      []string
+	extraFuncs       template.FuncMap
+	funcs            template.FuncMap
+	marks            placeholder.CodeSectionMarks
+	fsys             OutputFS
+	executor         executor.Executor
+	executorOptions  executor.Options
+	manifestFileName string
+	formatGoSource   bool
+	postProcessors   *PostProcessorRegistry
+	headerDirectives *HeaderDirectiveRegistry
+	parallelism      int
+	strict           bool
+}
+
+// A RendererOption configures a Renderer.
+type RendererOption func(*Renderer)
+
+// WithCodeGeneratorName sets the name of the generator mentioned by the file
+// header functions such as CodeSectionFileHeader.
+func WithCodeGeneratorName(name string) RendererOption {
+	return func(r *Renderer) { r.generatorName = name }
+}
+
+// WithInitialisms sets the initialisms kept as whole uppercased words by the
+// name conversion functions, replacing the default ones.
+func WithInitialisms(initialisms ...string) RendererOption {
+	return func(r *Renderer) { r.initialisms = initialisms }
+}
+
+// WithGoNamePrefixFunc sets the rule to prefix go names which do not start
+// with a letter, see GoNamePrefixFunc.
+func WithGoNamePrefixFunc(prefixFunc func(string) string) RendererOption {
+	return func(r *Renderer) { r.prefixFunc = prefixFunc }
+}
+
+// WithFuncs adds functions to the templates, overriding the built-in ones
+// with the same name.
+func WithFuncs(funcs template.FuncMap) RendererOption {
+	return func(r *Renderer) {
+		for name, f := range funcs {
+			r.extraFuncs[name] = f
+		}
+	}
+}
+
+// WithCodeSectionMarks sets the marks of the code regions merged into the
+// existing files.
+func WithCodeSectionMarks(marks ...placeholder.CodeSectionMark) RendererOption {
+	return func(r *Renderer) { r.marks = marks }
+}
+
+// WithOutputFS sets the file system the files are rendered to.
+func WithOutputFS(fsys OutputFS) RendererOption {
+	return func(r *Renderer) { r.fsys = fsys }
+}
+
+// WithExecutor sets the executor running the extra-rendering commands.
+func WithExecutor(e executor.Executor, options executor.Options) RendererOption {
+	return func(r *Renderer) {
+		r.executor = e
+		r.executorOptions = options
+	}
+}
+
+// WithManifestFileName sets the name of the manifest, DefaultManifestFileName
+// by default. An empty name disables the manifest and the orphan cleanup.
+func WithManifestFileName(name string) RendererOption {
+	return func(r *Renderer) { r.manifestFileName = name }
+}
+
+// WithFormatGoSource enables the in-process formatting of generated Go files.
+//
+// When enabled, Render applies the go post-processor, which formats the
+// rendered `.go` files with FormatGo before merging code regions and writing
+// them, instead of returning `goimports` commands.
+func WithFormatGoSource(enabled bool) RendererOption {
+	return func(r *Renderer) { r.formatGoSource = enabled }
+}
+
+// WithPostProcessors sets the registry of post-processors applied to the
+// rendered files. By default, a registry created with NewPostProcessorRegistry
+// is used.
+func WithPostProcessors(registry *PostProcessorRegistry) RendererOption {
+	return func(r *Renderer) { r.postProcessors = registry }
+}
+
+// WithHeaderDirectives sets the registry whose hooks act on the custom header
+// directives of the templates, HeaderDirectives by default. It should be the
+// one the templates were loaded with, see WithPackHeaderDirectives.
+func WithHeaderDirectives(registry *HeaderDirectiveRegistry) RendererOption {
+	return func(r *Renderer) { r.headerDirectives = registry }
+}
+
+// WithParallelism sets the maximum number of templates rendered concurrently.
+// Templates are rendered one at a time if it is lower than 2.
+//
+// Files are written, and results returned, in template order regardless.
+func WithParallelism(workers int) RendererOption {
+	return func(r *Renderer) { r.parallelism = workers }
+}
+
+// WithStrict makes the rendering of the names and contents of all the templates
+// fail when they use a missing map key, or when their output contains
+// `<no value>`, which is what a nil value renders to. Templates can opt in
+// individually with the `!!strict` header.
+func WithStrict(enabled bool) RendererOption {
+	return func(r *Renderer) { r.strict = enabled }
+}
+
+// NewRenderer creates a renderer.
+//
+// By default, it renders to the operating system file system, runs commands
+// as sub-processes and uses the default initialisms, code section marks and
+// manifest name, its own registry of post-processors and the default registry
+// of header directives, HeaderDirectives. Other package level variables are
+// ignored.
+func NewRenderer(options ...RendererOption) *Renderer {
+	r := &Renderer{
+		generatorName:    DefaultCodeGeneratorName,
+		initialisms:      DefaultInitialisms(),
+		extraFuncs:       template.FuncMap{},
+		marks:            append(placeholder.CodeSectionMarks{}, placeholder.DefaultCodeSectionMarks...),
+		fsys:             NewOSFS(),
+		executor:         executor.OSExecutor{},
+		manifestFileName: DefaultManifestFileName,
+		postProcessors:   NewPostProcessorRegistry(),
+		headerDirectives: HeaderDirectives,
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	r.names = NewNameConverter(r.initialisms, r.prefixFunc)
+	r.funcs = r.buildFuncMap()
+
+	return r
+}
+
+// withContext returns a copy of the renderer whose file system is bound to a
+// context, see ContextFS.
+func (r *Renderer) withContext(ctx context.Context) *Renderer {
+	bound := *r
+	bound.fsys = withContext(r.fsys, ctx)
+
+	return &bound
+}
+
+// defaultRenderer returns a renderer configured with the package level
+// variables, as they are when it is called.
+func defaultRenderer(fsys OutputFS) *Renderer {
+	return &Renderer{
+		generatorName:    CodeGeneratorName,
+		names:            defaultNameConverter(),
+		funcs:            defaultFuncMap(),
+		marks:            placeholder.DefaultCodeSectionMarks,
+		fsys:             fsys,
+		executor:         executor.OSExecutor{},
+		manifestFileName: DefaultManifestFileName,
+		postProcessors:   NewPostProcessorRegistry(),
+		headerDirectives: HeaderDirectives,
+	}
+}
+
+func (r *Renderer) buildFuncMap() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+
+	for name, f := range templatesFuncMap {
+		funcs[name] = f
+	}
+
+	funcs["ToGoName"] = r.names.ToGoName
+	funcs["ToVarName"] = r.names.ToVarName
+	funcs["ToFileName"] = r.names.ToFileName
+	funcs["ToCommandName"] = r.names.ToCommandName
+	funcs["ToGoPackageName"] = r.names.ToGoPackageName
+	funcs["ToHumanNameTitle"] = r.names.ToHumanNameTitle
+	funcs["ToEnvVarName"] = r.names.ToEnvVarName
+	funcs["CodeSectionFileHeader"] = func() string { return headerMessage(r.generatorName, codeSectionFileMessage) }
+	funcs["NonEditableFileHeader"] = func() string { return headerMessage(r.generatorName, nonEditableFileMessage) }
+	funcs["FullyEditableFileHeader"] = func() string { return headerMessage(r.generatorName, fullyEditableFileMessage) }
+
+	for name, f := range r.extraFuncs {
+		funcs[name] = f
+	}
+
+	return funcs
+}
+
+// FuncMap returns the functions available to the templates.
+func (r *Renderer) FuncMap() template.FuncMap {
+	funcs := make(template.FuncMap, len(r.funcs))
+
+	for name, f := range r.funcs {
+		funcs[name] = f
+	}
+
+	return funcs
+}
+
+// Names returns the name converter used by the templates functions.
+func (r *Renderer) Names() *NameConverter {
+	return r.names
+}
+
+// Execute runs the extra-rendering commands of a result from the output root
+// with the executor of the renderer.
+func (r *Renderer) Execute(ctx context.Context, root string, result *RenderResult) ([]executor.Result, error) {
+	return executor.Run(ctx, r.executor, root, result.Commands(), r.executorOptions)
+}
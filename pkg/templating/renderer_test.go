@@ -0,0 +1,156 @@
+package templating
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code.cestus.io/libs/codegenerator/pkg/executor"
+	"code.cestus.io/libs/codegenerator/pkg/placeholder"
+)
+
+func TestRendererConfiguration(t *testing.T) {
+	tmpl, err := LoadTemplate("name.txt.template", bytes.NewBufferString(
+		"!!filename {{ .Name | ToFileName }}.txt\n{{ NonEditableFileHeader }}\n{{ ToGoName .Name }}\n-- region CODE_REGION(Body)\n{{ Shout .Name }}\n-- endregion\n",
+	))
+	require.NoError(t, err)
+
+	m := NewMemFS()
+	existing := "Kept\n-- region CODE_REGION(Body)\nold\n-- endregion\n"
+	require.NoError(t, m.WriteFile(filepath.Join("b", "api_acme.txt"), []byte(existing), 0666))
+
+	first := NewRenderer(
+		WithOutputFS(m),
+		WithCodeGeneratorName("first"),
+		WithFuncs(map[string]interface{}{"Shout": strings.ToUpper}),
+		WithManifestFileName(""),
+	)
+	second := NewRenderer(
+		WithOutputFS(m),
+		WithCodeGeneratorName("second"),
+		WithInitialisms("ACME"),
+		WithCodeSectionMarks(placeholder.CodeSectionMark("--")),
+		WithFuncs(map[string]interface{}{"Shout": strings.ToLower}),
+	)
+
+	ctx := map[string]interface{}{"Name": "api acme"}
+
+	_, err = first.Render([]Template{tmpl}, "a", ctx)
+	require.NoError(t, err)
+	_, err = second.Render([]Template{tmpl}, "b", ctx)
+	require.NoError(t, err)
+
+	data, err := m.ReadFile(filepath.Join("a", "api_acme.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "Code generated by first\n\nDO NOT EDIT.\nAPIAcme\n-- region CODE_REGION(Body)\nAPI ACME\n-- endregion\n", string(data))
+
+	data, err = m.ReadFile(filepath.Join("b", "api_acme.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "Kept\n-- region CODE_REGION(Body)\napi acme\n-- endregion\n", string(data))
+
+	assert.Equal(t, []string{
+		filepath.Join("a", "api_acme.txt"),
+		filepath.Join("b", DefaultManifestFileName),
+		filepath.Join("b", "api_acme.txt"),
+	}, m.Paths())
+
+	assert.Equal(t, "ApiACME", second.Names().ToGoName("api acme"))
+	assert.Equal(t, CodeGeneratorName, DefaultCodeGeneratorName)
+}
+
+func TestRendererExecute(t *testing.T) {
+	tmpl, err := LoadTemplate("main.go.template", bytes.NewBufferString("package main\n"))
+	require.NoError(t, err)
+
+	fake := &executor.FakeExecutor{}
+	r := NewRenderer(WithOutputFS(NewMemFS()), WithExecutor(fake, executor.Options{}))
+
+	result, err := r.Render([]Template{tmpl}, "output", nil)
+	require.NoError(t, err)
+
+	results, err := r.Execute(context.Background(), "output", result)
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, []executor.Call{
+		{Dir: "output", Args: []string{"goimports", "-l", "-w", "./main.go"}},
+		{Dir: "output", Args: []string{"go", "generate", "./main.go"}},
+	}, fake.Calls())
+}
+
+func TestRendererRenderContext(t *testing.T) {
+	templates := loadFixturePack(t, "foo")
+	m := NewMemFS()
+	r := NewRenderer(WithOutputFS(m))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := r.RenderContext(ctx, templates, "output", "world")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, result)
+	assert.Empty(t, m.Paths())
+
+	result, err = r.RenderContext(context.Background(), templates, "output", "world")
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Files)
+}
+
+// contextFS fails once its context is done, and cancels it when writing a file
+// whose name contains cancelOn. It must be bound to a context before use.
+type contextFS struct {
+	*MemFS
+	ctx      context.Context
+	cancel   context.CancelFunc
+	cancelOn string
+}
+
+func (f contextFS) WithContext(ctx context.Context) OutputFS {
+	f.ctx = ctx
+	return f
+}
+
+func (f contextFS) err() error {
+	if f.ctx == nil {
+		return errors.New("no context")
+	}
+	return f.ctx.Err()
+}
+
+func (f contextFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if strings.Contains(name, f.cancelOn) {
+		f.cancel()
+	}
+	if err := f.err(); err != nil {
+		return err
+	}
+	return f.MemFS.WriteFile(name, data, perm)
+}
+
+func (f contextFS) Remove(name string) error {
+	if err := f.err(); err != nil {
+		return err
+	}
+	return f.MemFS.Remove(name)
+}
+
+func TestRendererContextFS(t *testing.T) {
+	templates := loadFixturePack(t, "foo")
+	m := NewMemFS()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := NewRenderer(WithOutputFS(contextFS{MemFS: m, cancel: cancel, cancelOn: "e.txt"}))
+
+	// The write is interrupted, and the files already written are removed.
+	result, err := r.RenderContext(ctx, templates, "output", "world")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, result)
+	assert.Empty(t, m.Paths())
+}
@@ -0,0 +1,100 @@
+package templating
+
+import "sort"
+
+// FileStatus describes what happened to a file during rendering.
+type FileStatus string
+
+const (
+	// StatusWritten is a file that was created or updated, including a file
+	// whose mode only was changed.
+	StatusWritten FileStatus = "written"
+	// StatusUnchanged is an existing file that was already up to date.
+	StatusUnchanged FileStatus = "unchanged"
+	// StatusSkippedCondition is a file whose `!!if` or `!!ifor` condition is false.
+	StatusSkippedCondition FileStatus = "skipped-condition"
+	// StatusSkippedExists is an existing file left as is because of the `!!if-not-exists` header.
+	StatusSkippedExists FileStatus = "skipped-exists"
+	// StatusRemoved is a file that was removed, either because it rendered
+	// empty with the `!!remove-if-empty` header, or because it was an orphan.
+	StatusRemoved FileStatus = "removed"
+)
+
+// A FileResult describes the outcome of rendering a single file.
+type FileResult struct {
+	// Template is the path of the template that produced the file.
+	Template string
+	// Pack is the name of the pack the template was loaded from.
+	Pack string
+	// Path is the path of the output file.
+	Path   string
+	Status FileStatus
+	// BytesBefore is the size of the file before rendering, 0 if it didn't exist.
+	BytesBefore int
+	// BytesAfter is the size of the file after rendering, 0 if it doesn't exist anymore.
+	BytesAfter int
+	// Regions are the identifiers of the code regions merged into the existing file.
+	Regions []string
+	// Commands are the extra-rendering commands to execute for the file.
+	Commands [][]string
+}
+
+// RenderResult is the outcome of rendering a list of templates.
+type RenderResult struct {
+	// Files are the rendered files, in template order, followed by the orphans
+	// that were removed.
+	Files []FileResult
+}
+
+// Generated returns the sorted paths of the files which exist after rendering.
+func (r *RenderResult) Generated() (generated []string) {
+	for _, file := range r.Files {
+		switch file.Status {
+		case StatusWritten, StatusUnchanged, StatusSkippedExists:
+			generated = append(generated, file.Path)
+		}
+	}
+	sort.Strings(generated)
+
+	return
+}
+
+// Commands returns the extra-rendering commands of all the files, in order.
+func (r *RenderResult) Commands() (cmds [][]string) {
+	for _, file := range r.Files {
+		cmds = append(cmds, file.Commands...)
+	}
+
+	return
+}
+
+// newFileResult describes the outcome of an applied action.
+func newFileResult(action Action) FileResult {
+	result := FileResult{
+		Template:    action.Template,
+		Pack:        action.Pack,
+		Path:        action.Path,
+		BytesBefore: len(action.Existing),
+		Regions:     action.Regions,
+		Commands:    action.Commands,
+	}
+
+	switch action.Kind {
+	case ActionCreate, ActionUpdate:
+		result.Status = StatusWritten
+		result.BytesAfter = len(action.Content)
+	case ActionUnchanged:
+		result.Status = StatusUnchanged
+		result.BytesAfter = len(action.Content)
+	case ActionSkipExists:
+		result.Status = StatusSkippedExists
+		result.BytesAfter = len(action.Existing)
+	case ActionSkipCondition:
+		result.Status = StatusSkippedCondition
+		result.BytesAfter = len(action.Existing)
+	case ActionRemove, ActionPrune:
+		result.Status = StatusRemoved
+	}
+
+	return result
+}
@@ -0,0 +1,65 @@
+package templating
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderWithResult(t *testing.T) {
+	templates := loadFixturePack(t, "plan")
+	goTemplate, err := LoadTemplate("main.go.template", bytes.NewBufferString("package {{ .Name }}\n"))
+	require.NoError(t, err)
+	templates = append(templates, goTemplate)
+
+	m := NewMemFS()
+	existing := "Hello\n// region CODE_REGION(Name)\nnobody\n// endregion\n"
+	require.NoError(t, m.WriteFile(filepath.Join("output", "updated.txt"), []byte(existing), 0666))
+	require.NoError(t, m.WriteFile(filepath.Join("output", "exists.txt"), []byte("Keep me\n"), 0666))
+
+	result, err := RenderFSWithResult(m, templates, "output", map[string]interface{}{"Name": "world"})
+	require.NoError(t, err)
+
+	byName := map[string]FileResult{}
+	for _, file := range result.Files {
+		byName[filepath.Base(file.Path)] = file
+	}
+	require.Len(t, byName, 7)
+
+	assert.Equal(t, FileResult{
+		Template:    "updated.txt.template",
+		Pack:        "plan",
+		Path:        filepath.Join("output", "updated.txt"),
+		Status:      StatusWritten,
+		BytesBefore: len(existing),
+		BytesAfter:  len(existing) - len("nobody") + len("world"),
+		Regions:     []string{"Name"},
+	}, byName["updated.txt"])
+	assert.Equal(t, StatusWritten, byName["created.txt"].Status)
+	assert.Equal(t, 0, byName["created.txt"].BytesBefore)
+	assert.Equal(t, StatusSkippedExists, byName["exists.txt"].Status)
+	assert.Equal(t, StatusSkippedCondition, byName["skipped.txt"].Status)
+	assert.Equal(t, StatusRemoved, byName["removed.txt"].Status)
+	assert.Equal(t, [][]string{
+		{"goimports", "-l", "-w", "./main.go"},
+		{"go", "generate", "./main.go"},
+	}, byName["main.go"].Commands)
+
+	assert.Equal(t, result.Commands(), byName["main.go"].Commands)
+	assert.Equal(t, []string{
+		filepath.Join("output", "created.txt"),
+		filepath.Join("output", "exists.txt"),
+		filepath.Join("output", "main.go"),
+		filepath.Join("output", "unchanged.txt"),
+		filepath.Join("output", "updated.txt"),
+	}, result.Generated())
+
+	result, err = RenderFSWithResult(m, templates, "output", map[string]interface{}{"Name": "world"})
+	require.NoError(t, err)
+	for _, file := range result.Files {
+		assert.NotEqual(t, StatusWritten, file.Status, file.Path)
+	}
+}
@@ -0,0 +1,330 @@
+package templating
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// SchemaFileName is the name of the optional JSON Schema at the root of a
+// pack. It is never rendered.
+//
+// When the rendering context is a map or a struct, it is validated against the
+// schemas of the packs of the templates before anything is rendered.
+const SchemaFileName = "schema.json"
+
+// schemaURL is the URL references in a schema are resolved against.
+const schemaURL = "pack:///" + SchemaFileName
+
+// A Schema is a JSON Schema, draft 2020-12 unless it declares another one
+// with `$schema`. Formats are asserted.
+//
+// References to other documents aren't supported: the schema of a pack must
+// be self-contained.
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// A SchemaViolation is a value which doesn't match a schema.
+type SchemaViolation struct {
+	// Pointer is the JSON pointer of the value, in URI fragment form.
+	Pointer string
+	Message string
+}
+
+// SchemaError is returned when a context doesn't match a schema.
+type SchemaError struct {
+	// Pack is the name of the pack of the schema.
+	Pack       string
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	b := &strings.Builder{}
+
+	if e.Pack != "" {
+		fmt.Fprintf(b, "the context doesn't match the schema of pack `%s`:", e.Pack)
+	} else {
+		fmt.Fprintf(b, "the context doesn't match the schema:")
+	}
+
+	for _, violation := range e.Violations {
+		fmt.Fprintf(b, "\n%s: %s", violation.Pointer, violation.Message)
+	}
+
+	return b.String()
+}
+
+// ParseSchema parses a JSON Schema.
+func ParseSchema(data []byte) (*Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat = true
+	compiler.AssertContent = true
+	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("unsupported reference `%s`: only references inside the schema are supported", url)
+	}
+
+	if err := compiler.AddResource(schemaURL, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("parsing schema: %s", err)
+	}
+
+	schema, err := compiler.Compile(schemaURL)
+
+	if err != nil {
+		return nil, fmt.Errorf("compiling schema: %s", err)
+	}
+
+	return &Schema{schema: schema}, nil
+}
+
+// readSchema reads the schema at the root of a pack file system, if any.
+func readSchema(fsys fs.FS) (*Schema, error) {
+	data, err := fs.ReadFile(fsys, SchemaFileName)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	schema, err := ParseSchema(data)
+
+	if err != nil {
+		return nil, fmt.Errorf("`%s`: %s", SchemaFileName, err)
+	}
+
+	return schema, nil
+}
+
+// Validate validates a context against the schema. Contexts which are neither
+// maps nor structs are not validated.
+//
+// The context is validated as templates see it: the properties of a struct
+// are its exported fields, named after them regardless of their json tags.
+//
+// Returns a *SchemaError listing every violation.
+func (s *Schema) Validate(ctx interface{}) error {
+	value := reflect.ValueOf(ctx)
+
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Map && value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	err := s.schema.Validate(contextInstance(reflect.ValueOf(ctx), map[contextReference]bool{}))
+
+	var validationErr *jsonschema.ValidationError
+
+	if errors.As(err, &validationErr) {
+		violations := schemaViolations(validationErr)
+
+		sort.SliceStable(violations, func(i, j int) bool {
+			if violations[i].Pointer != violations[j].Pointer {
+				return violations[i].Pointer < violations[j].Pointer
+			}
+			return violations[i].Message < violations[j].Message
+		})
+
+		return &SchemaError{Violations: violations}
+	} else if err != nil {
+		return fmt.Errorf("validating the context: %s", err)
+	}
+
+	return nil
+}
+
+// schemaViolations returns the innermost causes of a validation error.
+func schemaViolations(err *jsonschema.ValidationError) (violations []SchemaViolation) {
+	if len(err.Causes) == 0 {
+		return []SchemaViolation{{Pointer: "#" + err.InstanceLocation, Message: err.Message}}
+	}
+
+	for _, cause := range err.Causes {
+		violations = append(violations, schemaViolations(cause)...)
+	}
+
+	return
+}
+
+// A contextReference identifies a pointer, map or slice of a rendering context.
+type contextReference struct {
+	ptr    uintptr
+	typ    reflect.Type
+	length int
+}
+
+// contextInstance converts a value of a rendering context to the JSON value
+// validated against a schema. Values which can't be represented in JSON, such
+// as functions, are null, and so are the references to a value being
+// converted, e.g. the parent of a node of a tree.
+//
+// visiting holds the pointers, maps and slices being converted.
+func contextInstance(value reflect.Value, visiting map[contextReference]bool) interface{} {
+	for {
+		if value.IsValid() && value.CanInterface() {
+			if marshaler, ok := value.Interface().(encoding.TextMarshaler); ok {
+				if text, err := marshaler.MarshalText(); err == nil {
+					return string(text)
+				}
+			}
+		}
+
+		if value.Kind() != reflect.Pointer && value.Kind() != reflect.Interface {
+			break
+		}
+
+		if value.IsNil() {
+			return nil
+		}
+
+		if value.Kind() == reflect.Pointer {
+			ref := contextReference{ptr: value.Pointer(), typ: value.Type()}
+
+			if visiting[ref] {
+				return nil
+			}
+
+			visiting[ref] = true
+			defer delete(visiting, ref)
+		}
+
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Bool:
+		return value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return json.Number(strconv.FormatInt(value.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return json.Number(strconv.FormatUint(value.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		return value.Float()
+	case reflect.String:
+		return value.String()
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice {
+			if value.IsNil() {
+				return nil
+			}
+
+			ref := contextReference{ptr: value.Pointer(), typ: value.Type(), length: value.Len()}
+
+			if visiting[ref] {
+				return nil
+			}
+
+			visiting[ref] = true
+			defer delete(visiting, ref)
+		}
+
+		items := make([]interface{}, value.Len())
+
+		for i := range items {
+			items[i] = contextInstance(value.Index(i), visiting)
+		}
+
+		return items
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+
+		ref := contextReference{ptr: value.Pointer(), typ: value.Type()}
+
+		if visiting[ref] {
+			return nil
+		}
+
+		visiting[ref] = true
+		defer delete(visiting, ref)
+
+		properties := make(map[string]interface{}, value.Len())
+
+		for iter := value.MapRange(); iter.Next(); {
+			properties[fmt.Sprint(iter.Key().Interface())] = contextInstance(iter.Value(), visiting)
+		}
+
+		return properties
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+
+		// Templates access the exported fields, including the promoted ones,
+		// by their Go name.
+		for _, field := range reflect.VisibleFields(value.Type()) {
+			if !field.IsExported() {
+				continue
+			}
+
+			if fieldValue, err := value.FieldByIndexErr(field.Index); err == nil {
+				properties[field.Name] = contextInstance(fieldValue, visiting)
+			}
+		}
+
+		return properties
+	}
+
+	return nil
+}
+
+// validateContext validates a context against the schemas of the packs of a
+// list of templates, in template order.
+func validateContext(templates []Template, ctx interface{}) error {
+	validated := map[*Schema]bool{}
+
+	for _, tmpl := range templates {
+		t, ok := tmpl.(schemaTemplate)
+
+		if !ok {
+			continue
+		}
+
+		schema := t.getSchema()
+
+		if schema == nil || validated[schema] {
+			continue
+		}
+
+		validated[schema] = true
+
+		if err := schema.Validate(ctx); err != nil {
+			if schemaErr, ok := err.(*SchemaError); ok {
+				schemaErr.Pack = templatePack(tmpl)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// schemaTemplate is implemented by the templates loaded from a pack with a schema.
+type schemaTemplate interface {
+	getSchema() *Schema
+}
+
+// withSchema records the schema of the pack a template was loaded from.
+func withSchema(t Template, schema *Schema) Template {
+	if impl, ok := t.(templateImpl); ok && schema != nil {
+		impl.schema = schema
+		return impl
+	}
+
+	return t
+}
@@ -0,0 +1,143 @@
+package templating
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderValidatesSchema(t *testing.T) {
+	templates := loadFixturePack(t, "schema")
+	require.Len(t, templates, 1)
+
+	m := NewMemFS()
+	_, _, err := RenderFS(m, templates, "output", map[string]interface{}{
+		"Name":  "world",
+		"Port":  1.5,
+		"Extra": true,
+		"Resources": []interface{}{
+			map[string]interface{}{"Kind": "list"},
+			map[string]interface{}{"Kind": "other"},
+			map[string]interface{}{},
+		},
+	})
+	require.Error(t, err)
+	assert.Empty(t, m.Paths())
+
+	var schemaErr *SchemaError
+	require.True(t, errors.As(err, &schemaErr))
+	assert.Equal(t, "the context doesn't match the schema of pack `schema`:\n"+
+		"#: additionalProperties 'Extra' not allowed\n"+
+		"#/Name: does not match pattern '^[A-Z]'\n"+
+		"#/Port: expected integer, but got number\n"+
+		"#/Resources/1/Kind: value must be one of \"list\", \"item\"\n"+
+		"#/Resources/2: missing properties: 'Kind'", err.Error())
+
+	// Structs are validated with the field names templates use, regardless of
+	// their json tags, and without their unexported fields.
+	type resource struct {
+		Kind string `json:"kind"`
+	}
+	ctx := struct {
+		Name      string `json:"name"`
+		Port      int    `json:"port,omitempty"`
+		Resources []resource
+		internal  bool
+	}{"World", 8080, []resource{{Kind: "item"}}, true}
+
+	_, _, err = RenderFS(m, templates, "output", ctx)
+	require.NoError(t, err)
+	data, err := m.ReadFile(filepath.Join("output", "hello.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello World\n", string(data))
+
+	// Contexts which are neither maps nor structs are not validated.
+	assert.NoError(t, validateContext(templates, "world"))
+}
+
+func TestSchemaKeywords(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{
+		"dependentRequired": {"a": ["z"]},
+		"properties": {
+			"a": {"anyOf": [{"type": "string"}, {"type": "boolean"}]},
+			"b": {"oneOf": [{"type": "number"}, {"type": "integer"}]},
+			"c": {"not": {"const": 42}},
+			"d": {"type": ["string", "null"], "minLength": 2, "maxLength": 3},
+			"e": {"allOf": [{"exclusiveMinimum": 0}, {"exclusiveMaximum": 10}]},
+			"f": false,
+			"g": {"format": "email"},
+			"h": {"uniqueItems": true},
+			"i": {"multipleOf": 5},
+			"j": {"propertyNames": {"pattern": "^[a-z]+$"}, "patternProperties": {"^x": {"type": "integer"}}},
+			"k": {"if": {"const": "tcp"}, "then": {"minLength": 5}},
+			"l": {"minProperties": 1}
+		}
+	}`))
+	require.NoError(t, err)
+
+	err = schema.Validate(map[string]interface{}{
+		"a": 1, "b": 2, "c": 42, "d": "a", "e": 10, "f": nil, "g": "nope", "h": []int{1, 1}, "i": 7,
+		"j": map[string]interface{}{"B": 1, "x": "s"}, "k": "tcp", "l": map[string]int{},
+	})
+	require.Error(t, err)
+	assert.Equal(t, []SchemaViolation{
+		{Pointer: "#", Message: "property 'z' is required, if 'a' property exists"},
+		{Pointer: "#/a", Message: "expected boolean, but got number"},
+		{Pointer: "#/a", Message: "expected string, but got number"},
+		{Pointer: "#/b", Message: "valid against schemas at indexes 0 and 1"},
+		{Pointer: "#/c", Message: "not failed"},
+		{Pointer: "#/d", Message: "length must be >= 2, but got 1"},
+		{Pointer: "#/e", Message: "must be < 10 but found 10"},
+		{Pointer: "#/f", Message: "not allowed"},
+		{Pointer: "#/g", Message: "'nope' is not valid 'email'"},
+		{Pointer: "#/h", Message: "items at index 0 and 1 are equal"},
+		{Pointer: "#/i", Message: "7 not multipleOf 5"},
+		{Pointer: "#/j/B", Message: "does not match pattern '^[a-z]+$'"},
+		{Pointer: "#/j/x", Message: "expected integer, but got string"},
+		{Pointer: "#/k", Message: "length must be >= 5, but got 3"},
+		{Pointer: "#/l", Message: "minimum 1 properties allowed, but found 0 properties"},
+	}, err.(*SchemaError).Violations)
+
+	assert.NoError(t, schema.Validate(map[string]interface{}{
+		"a": true, "b": 1.5, "c": 41, "d": nil, "e": 5, "g": "jane@example.com", "h": []int{1, 2}, "i": 10,
+		"j": map[string]interface{}{"x": 1}, "k": "https", "l": map[string]int{"a": 1}, "z": true,
+	}))
+
+	_, err = ParseSchema([]byte(`{"pattern": "("}`))
+	assert.ErrorContains(t, err, "'(' is not valid 'regex'")
+
+	_, err = ParseSchema([]byte(`{"$ref": "other.json"}`))
+	assert.ErrorContains(t, err, "unsupported reference `pack:///other.json`: only references inside the schema are supported")
+}
+
+func TestSchemaCyclicContext(t *testing.T) {
+	type node struct {
+		Name     string
+		Parent   *node
+		Children []*node
+	}
+
+	root := &node{Name: "root"}
+	root.Children = []*node{{Name: "child", Parent: root}}
+
+	schema, err := ParseSchema([]byte(`{
+		"type": "object",
+		"properties": {
+			"Children": {"items": {"properties": {"Name": {"const": "leaf"}, "Parent": {"type": "null"}}}}
+		}
+	}`))
+	require.NoError(t, err)
+
+	err = schema.Validate(root)
+	require.Error(t, err)
+	assert.Equal(t, []SchemaViolation{
+		{Pointer: "#/Children/0/Name", Message: "value must be \"leaf\""},
+	}, err.(*SchemaError).Violations)
+
+	values := map[string]interface{}{"Name": "root"}
+	values["Self"] = values
+	assert.NoError(t, schema.Validate(values))
+}
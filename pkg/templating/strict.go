@@ -0,0 +1,39 @@
+package templating
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"unicode/utf8"
+)
+
+// noValue is what text/template renders for missing and nil values.
+const noValue = "<no value>"
+
+const strictOption = "missingkey=error"
+
+// newTemplate creates a template with the specified functions.
+func newTemplate(funcs template.FuncMap, strict bool) *template.Template {
+	tmpl := template.New("").Funcs(funcs)
+
+	if strict {
+		tmpl.Option(strictOption)
+	}
+
+	return tmpl
+}
+
+// checkNoValue returns an error locating the first `<no value>` of an output.
+func checkNoValue(output []byte) error {
+	i := bytes.Index(output, []byte(noValue))
+
+	if i < 0 {
+		return nil
+	}
+
+	before := output[:i]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := utf8.RuneCount(before[bytes.LastIndexByte(before, '\n')+1:]) + 1
+
+	return fmt.Errorf("output contains `%s` on line %d, column %d", noValue, line, column)
+}
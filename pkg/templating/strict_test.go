@@ -0,0 +1,32 @@
+package templating
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderStrict(t *testing.T) {
+	lenient := loadTemplateString(t, "lenient.txt.template", "Hello {{ .Nmae }}\n")
+	nilValue := loadTemplateString(t, "nil.txt.template", "!!strict\nHello\n  {{ .Name }}\n")
+	name := loadTemplateString(t, "name.txt.template", "!!strict\n!!filename {{ .Flie }}.txt\nHello\n")
+	ctx := map[string]interface{}{"Name": nil}
+
+	m := NewMemFS()
+	_, _, err := RenderFS(m, []Template{lenient}, "output", ctx)
+	require.NoError(t, err)
+	data, err := m.ReadFile(filepath.Join("output", "lenient.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello <no value>\n", string(data))
+
+	_, err = NewRenderer(WithOutputFS(NewMemFS()), WithStrict(true)).Render([]Template{lenient}, "output", ctx)
+	assert.EqualError(t, err, "rendering content for template `lenient.txt.template`: template: :1:9: executing \"\" at <.Nmae>: map has no entry for key \"Nmae\"")
+
+	_, _, err = RenderFS(NewMemFS(), []Template{nilValue}, "output", ctx)
+	assert.EqualError(t, err, "rendering content for template `nil.txt.template`: output contains `<no value>` on line 2, column 3")
+
+	_, _, err = RenderFS(NewMemFS(), []Template{name}, "output", ctx)
+	assert.EqualError(t, err, "rendering name for template `name.txt.template`: template: :1:3: executing \"\" at <.Flie>: map has no entry for key \"Flie\"")
+}
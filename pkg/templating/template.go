@@ -7,8 +7,6 @@ import (
 	"path/filepath"
 	"strings"
 	"text/template"
-
-	sprig "github.com/Masterminds/sprig/v3"
 )
 
 // A TemplateName represents the name of a template.
@@ -30,9 +28,13 @@ type templatedTemplateName struct {
 }
 
 func (n templatedTemplateName) Render(ctx interface{}) (string, error) {
+	return n.render(ctx, defaultFuncMap(), false)
+}
+
+func (n templatedTemplateName) render(ctx interface{}, funcs template.FuncMap, strict bool) (string, error) {
 	relDir, filename := filepath.Split(n.RelPath)
 	if len(n.Source) > 0 {
-		tmpl, err := template.New("").Funcs(templatesFuncMap).Funcs(sprig.TxtFuncMap()).Parse(n.Source)
+		tmpl, err := newTemplate(funcs, strict).Parse(n.Source)
 		if err != nil {
 			return "", err
 		}
@@ -48,7 +50,7 @@ func (n templatedTemplateName) Render(ctx interface{}) (string, error) {
 	for _, r := range n.PathReplace {
 		relDir = strings.ReplaceAll(relDir, r.old, r.new)
 	}
-	tmpl, err := template.New("").Funcs(templatesFuncMap).Funcs(sprig.TxtFuncMap()).Parse(relDir)
+	tmpl, err := newTemplate(funcs, strict).Parse(relDir)
 
 	if err != nil {
 		return "", err
@@ -59,6 +61,12 @@ func (n templatedTemplateName) Render(ctx interface{}) (string, error) {
 		return "", err
 	}
 	relDir = newPath.String()
+
+	if strict {
+		if err = checkNoValue([]byte(filepath.Join(relDir, filename))); err != nil {
+			return "", err
+		}
+	}
 	//
 
 	return filepath.Join(relDir, filename), nil
@@ -70,12 +78,14 @@ type TemplateContent interface {
 	Render(w io.Writer, ctx interface{}) error
 }
 
+// rawTemplateContent keeps its source in memory so that a template can be
+// rendered more than once.
 type rawTemplateContent struct {
-	Source io.Reader
+	Source []byte
 }
 
 func (c rawTemplateContent) Render(w io.Writer, ctx interface{}) error {
-	_, err := io.Copy(w, c.Source)
+	_, err := w.Write(c.Source)
 	return err
 }
 
@@ -83,22 +93,98 @@ type templatedTemplateContent struct {
 	TemplateContent
 	LeftDelimiter  string
 	RightDelimiter string
+
+	// partials holds the defines shared by the templates of a pack, if any.
+	partials *template.Template
 }
 
 func (c templatedTemplateContent) Render(w io.Writer, ctx interface{}) error {
+	return c.render(w, ctx, defaultFuncMap(), false)
+}
+
+func (c templatedTemplateContent) render(w io.Writer, ctx interface{}, funcs template.FuncMap, strict bool) error {
 	source := &bytes.Buffer{}
 
 	if err := c.TemplateContent.Render(source, ctx); err != nil {
 		return err
 	}
 
-	tmpl, err := template.New("").Funcs(templatesFuncMap).Funcs(sprig.TxtFuncMap()).Delims(c.LeftDelimiter, c.RightDelimiter).Parse(source.String())
+	tmpl := newTemplate(funcs, strict)
+
+	if c.partials != nil {
+		var err error
+
+		if tmpl, err = c.partials.Clone(); err != nil {
+			return err
+		}
+		if strict {
+			tmpl.Option(strictOption)
+		}
+	}
+
+	tmpl, err := tmpl.Funcs(funcs).Delims(c.LeftDelimiter, c.RightDelimiter).Parse(source.String())
 
 	if err != nil {
 		return err
 	}
 
-	return tmpl.Execute(w, ctx)
+	if !strict {
+		return tmpl.Execute(w, ctx)
+	}
+
+	output := &bytes.Buffer{}
+
+	if err = tmpl.Execute(output, ctx); err != nil {
+		return err
+	}
+
+	if err = checkNoValue(output.Bytes()); err != nil {
+		return err
+	}
+
+	_, err = w.Write(output.Bytes())
+
+	return err
+}
+
+// The templates of this package can be rendered with the functions of a
+// Renderer instead of the package level ones, and in strict mode.
+type (
+	funcsTemplateName interface {
+		render(ctx interface{}, funcs template.FuncMap, strict bool) (string, error)
+	}
+
+	funcsTemplateContent interface {
+		render(w io.Writer, ctx interface{}, funcs template.FuncMap, strict bool) error
+	}
+
+	funcsTemplate interface {
+		renderGeneratorCommands(ctx interface{}, funcs template.FuncMap) ([][]string, error)
+	}
+)
+
+func renderName(name TemplateName, ctx interface{}, funcs template.FuncMap, strict bool) (string, error) {
+	if n, ok := name.(funcsTemplateName); ok {
+		return n.render(ctx, funcs, strict)
+	}
+
+	return name.Render(ctx)
+}
+
+func renderContent(content TemplateContent, w io.Writer, ctx interface{}, funcs template.FuncMap, strict bool) error {
+	if c, ok := content.(funcsTemplateContent); ok {
+		return c.render(w, ctx, funcs, strict)
+	}
+
+	return content.Render(w, ctx)
+}
+
+func renderGeneratorCommands(tmpl Template, ctx interface{}, funcs template.FuncMap) ([][]string, error) {
+	if t, ok := tmpl.(funcsTemplate); ok {
+		return t.renderGeneratorCommands(ctx, funcs)
+	}
+
+	return tmpl.RenderGeneratorCommands(ctx)
 }
 
 // A Template represents a file or directory to render.
@@ -112,28 +198,80 @@ type Template interface {
 
 type templateImpl struct {
 	Path    string
+	Pack    string
 	Name    TemplateName
 	Content TemplateContent
 	Header  Header
+
+	// schema is the schema of the pack the template was loaded from, if any.
+	schema *Schema
+	// modes are the modes of the pack the template was loaded from.
+	modes []packMode
+	// manifest is the manifest of the pack the template was loaded from, if any.
+	manifest *PackManifest
 }
 
-func (t templateImpl) GetPath() string             { return t.Path }
-func (t templateImpl) GetName() TemplateName       { return t.Name }
-func (t templateImpl) GetContent() TemplateContent { return t.Content }
-func (t templateImpl) GetHeader() Header           { return t.Header }
-func (t templateImpl) RenderGeneratorCommands(ctx interface{}) (commands [][]string, err error) {
-	for _, cmd := range t.Header.GeneratorCommands {
-		tmpl, err := template.New("").Funcs(templatesFuncMap).Funcs(sprig.TxtFuncMap()).Parse(cmd)
+// A packTemplate knows the name of the pack it was loaded from.
+type packTemplate interface {
+	GetPack() string
+}
+
+// templatePack returns the name of the pack a template was loaded from, if any.
+func templatePack(t Template) string {
+	if p, ok := t.(packTemplate); ok {
+		return p.GetPack()
+	}
+
+	return ""
+}
+
+// withPack records the name of the pack a template was loaded from.
+func withPack(t Template, pack string) Template {
+	if impl, ok := t.(templateImpl); ok {
+		impl.Pack = pack
+		return impl
+	}
+
+	return t
+}
+
+func (t templateImpl) GetPath() string                { return t.Path }
+func (t templateImpl) GetPack() string                { return t.Pack }
+func (t templateImpl) GetName() TemplateName          { return t.Name }
+func (t templateImpl) GetContent() TemplateContent    { return t.Content }
+func (t templateImpl) GetHeader() Header              { return t.Header }
+func (t templateImpl) getSchema() *Schema             { return t.schema }
+func (t templateImpl) getModes() []packMode           { return t.modes }
+func (t templateImpl) getPackManifest() *PackManifest { return t.manifest }
+func (t templateImpl) RenderGeneratorCommands(ctx interface{}) ([][]string, error) {
+	return t.renderGeneratorCommands(ctx, defaultFuncMap())
+}
+
+func (t templateImpl) renderGeneratorCommands(ctx interface{}, funcs template.FuncMap) (commands [][]string, err error) {
+	for i, cmd := range t.Header.GeneratorCommands {
+		line := t.Header.generatorCommandLine(i)
+		tmpl, err := template.New("").Funcs(funcs).Parse(cmd)
 
 		if err != nil {
-			return nil, fmt.Errorf("failed to initialize rendering of generator command (%s): %s", cmd, err)
+			return nil, fmt.Errorf("failed to initialize rendering of generator command (%s) in template `%s` on line %d: %s", cmd, t.Path, line, err)
 		}
 
 		cmdline := &bytes.Buffer{}
 		err = tmpl.Execute(cmdline, ctx)
 
 		if err != nil {
-			return nil, fmt.Errorf("failed to render generator command (%s): %s", cmd, err)
+			return nil, fmt.Errorf("failed to render generator command (%s) in template `%s` on line %d: %s", cmd, t.Path, line, err)
+		}
+
+		args, err := splitCommandLine(cmdline.String())
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse generator command (%s) in template `%s` on line %d: %s", cmdline, t.Path, line, err)
+		}
+
+		// A command may render empty on purpose, e.g. when it is conditional.
+		if len(args) > 0 {
+			commands = append(commands, args)
 		}
 	}
 
@@ -142,6 +280,12 @@ func (t templateImpl) RenderGeneratorCommands(ctx interface{}) (commands [][]str
 
 // LoadTemplate loads a template from a reader
 func LoadTemplate(path string, r io.Reader) (template Template, err error) {
+	return HeaderDirectives.LoadTemplate(path, r)
+}
+
+// LoadTemplate is like the package level LoadTemplate but parses the custom
+// header directives of the template with the registry.
+func (r *HeaderDirectiveRegistry) LoadTemplate(path string, source io.Reader) (template Template, err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("loading template from %s: %s", path, err)
@@ -150,12 +294,10 @@ func LoadTemplate(path string, r io.Reader) (template Template, err error) {
 
 	var data []byte
 
-	if data, err = io.ReadAll(r); err != nil {
+	if data, err = io.ReadAll(source); err != nil {
 		return
 	}
 
-	r = bytes.NewBuffer(data)
-
 	var templateName TemplateName
 	var templateContent TemplateContent
 	var header Header
@@ -165,12 +307,17 @@ func LoadTemplate(path string, r io.Reader) (template Template, err error) {
 		path := path[:len(path)-9]
 
 		var reader io.Reader
-		reader, err = ParseHeaders(r, &header)
+		reader, err = r.ParseHeaders(bytes.NewBuffer(data), &header)
 
 		if err != nil {
 			return
 		}
 
+		var body []byte
+		if body, err = io.ReadAll(reader); err != nil {
+			return
+		}
+
 		if header.Filename != "" || len(header.PathReplace) > 0 {
 			templateName = templatedTemplateName{
 				RelPath:     path,
@@ -184,7 +331,7 @@ func LoadTemplate(path string, r io.Reader) (template Template, err error) {
 		}
 		templateContent = templatedTemplateContent{
 			TemplateContent: rawTemplateContent{
-				Source: reader,
+				Source: body,
 			},
 			LeftDelimiter:  header.Delimiters[0],
 			RightDelimiter: header.Delimiters[1],
@@ -194,7 +341,7 @@ func LoadTemplate(path string, r io.Reader) (template Template, err error) {
 			RelPath: path,
 		}
 		templateContent = rawTemplateContent{
-			Source: r,
+			Source: data,
 		}
 	}
 
@@ -0,0 +1,67 @@
+package templating
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected []string
+	}{
+		{"", nil},
+		{"  go   generate\t./... ", []string{"go", "generate", "./..."}},
+		{`echo 'hello world' "a \"b\" \c" d\ e`, []string{"echo", "hello world", `a "b" \c`, "d e"}},
+		{`echo '' "" x`, []string{"echo", "", "", "x"}},
+		{`protoc --go_out="paths=source_relative:."`, []string{"protoc", "--go_out=paths=source_relative:."}},
+	}
+
+	for _, tc := range testCases {
+		args, err := splitCommandLine(tc.line)
+		require.NoError(t, err, tc.line)
+		assert.Equal(t, tc.expected, args, tc.line)
+	}
+
+	for _, line := range []string{`echo "foo`, `echo 'foo`, `echo foo\`} {
+		_, err := splitCommandLine(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestRenderGeneratorCommands(t *testing.T) {
+	templates := loadFixturePack(t, "commands")
+	require.Len(t, templates, 1)
+
+	cmds, err := templates[0].RenderGeneratorCommands(map[string]interface{}{"Name": "FooBar", "Lint": true})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"protoc", "--go_out=.", "FooBar.proto"},
+		{"mockgen", "-destination", "foo_bar mock.go", "-source", "foo_bar.go"},
+		{"golint", "./..."},
+	}, cmds)
+}
+
+func TestRenderGeneratorCommandsError(t *testing.T) {
+	tmpl, err := LoadTemplate("gen.txt.template", bytes.NewBufferString("!!filename gen.txt\n!!generator-command echo '{{ .Name }}\nHello\n"))
+	require.NoError(t, err)
+
+	_, err = tmpl.RenderGeneratorCommands(map[string]interface{}{"Name": "world"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "`gen.txt.template` on line 2")
+	assert.Contains(t, err.Error(), "unterminated single quote")
+}
+
+func TestRenderWithGeneratorCommands(t *testing.T) {
+	templates := loadFixturePack(t, "commands")
+
+	_, cmds, err := RenderFS(NewMemFS(), templates, "output", map[string]interface{}{"Name": "FooBar"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"protoc", "--go_out=.", "FooBar.proto"},
+		{"mockgen", "-destination", "foo_bar mock.go", "-source", "foo_bar.go"},
+	}, cmds)
+}
@@ -0,0 +1,173 @@
+package templating
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// tempSuffix is appended to the name of the temporary files written before
+// being renamed to their final name.
+const tempSuffix = ".codegenerator-tmp"
+
+// A transaction applies changes to an OutputFS and records how to revert
+// them, so that a failed rendering leaves the output untouched.
+type transaction struct {
+	fsys OutputFS
+	undo []func() error
+}
+
+func newTransaction(fsys OutputFS) *transaction {
+	return &transaction{fsys: fsys}
+}
+
+// mkdirAll creates a directory along with any necessary parents.
+func (t *transaction) mkdirAll(path string) error {
+	var created []string
+
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if _, err := t.fsys.Stat(dir); err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		created = append(created, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	if err := t.fsys.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+
+	// created lists the deepest directory first, and undo runs in reverse
+	// order, so it must be recorded last to be removed first.
+	for i := len(created) - 1; i >= 0; i-- {
+		dir := created[i]
+		t.undo = append(t.undo, func() error { return t.fsys.Remove(dir) })
+	}
+
+	return nil
+}
+
+// replace atomically sets the content and mode of a file by writing a
+// temporary file next to it and renaming it. If perm is 0, the file is created
+// with 0666, before umask, and the mode of an existing file is preserved.
+func (t *transaction) replace(path string, data []byte, perm fs.FileMode) error {
+	if err := t.mkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	previous, previousMode, err := t.read(path)
+
+	if err != nil {
+		return err
+	}
+
+	exact := perm != 0 || previous != nil
+
+	switch {
+	case perm != 0:
+	case previous != nil:
+		perm = previousMode
+	default:
+		perm = 0666
+	}
+
+	if err = t.write(path, data, perm, exact); err != nil {
+		return err
+	}
+
+	t.undo = append(t.undo, func() error {
+		if previous == nil {
+			return t.fsys.Remove(path)
+		}
+		return t.write(path, previous, previousMode, true)
+	})
+
+	return nil
+}
+
+// remove deletes a file if it exists.
+func (t *transaction) remove(path string) error {
+	previous, previousMode, err := t.read(path)
+
+	if err != nil || previous == nil {
+		return err
+	}
+
+	if err = t.fsys.Remove(path); err != nil {
+		return err
+	}
+
+	t.undo = append(t.undo, func() error { return t.write(path, previous, previousMode, true) })
+
+	return nil
+}
+
+// read returns the content and mode of a file, or a nil content if it doesn't exist.
+func (t *transaction) read(path string) ([]byte, fs.FileMode, error) {
+	info, err := t.fsys.Stat(path)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	data, err := t.fsys.ReadFile(path)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if data == nil {
+		data = []byte{}
+	}
+
+	return data, info.Mode().Perm(), nil
+}
+
+// write replaces a file with a temporary one. If exact is true and the file
+// system is a ChmodFS, the mode is set regardless of the umask.
+func (t *transaction) write(path string, data []byte, perm fs.FileMode, exact bool) error {
+	tmpPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+tempSuffix)
+
+	if err := t.fsys.WriteFile(tmpPath, data, perm); err != nil {
+		return err
+	}
+
+	if chmodFS, ok := t.fsys.(ChmodFS); ok && exact {
+		if err := chmodFS.Chmod(tmpPath, perm); err != nil {
+			t.fsys.Remove(tmpPath)
+			return err
+		}
+	}
+
+	if err := t.fsys.Rename(tmpPath, path); err != nil {
+		t.fsys.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+// rollback reverts all the changes applied so far, most recent first.
+func (t *transaction) rollback() error {
+	var errs []error
+
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		if err := t.undo[i](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	t.undo = nil
+
+	if len(errs) > 0 {
+		return fmt.Errorf("rolling back: %s", errors.Join(errs...))
+	}
+
+	return nil
+}
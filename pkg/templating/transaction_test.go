@@ -0,0 +1,79 @@
+package templating
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingFS fails renaming a file to a given path.
+type failingFS struct {
+	*MemFS
+	failOn string
+}
+
+func (f failingFS) Rename(oldpath, newpath string) error {
+	if filepath.Clean(newpath) == f.failOn {
+		return errors.New("disk full")
+	}
+	return f.MemFS.Rename(oldpath, newpath)
+}
+
+func TestRenderRollback(t *testing.T) {
+	templates := loadFixturePack(t, "foo")
+	m := NewMemFS()
+	before := map[string]string{
+		filepath.Join("output", "d.txt"): "Updated text.\n\n// region CODE_REGION(Foo)\nText that will be lost\n// endregion\n",
+		filepath.Join("output", "f.txt"): "This is some stuff.\n",
+	}
+	for path, content := range before {
+		require.NoError(t, m.WriteFile(path, []byte(content), 0600))
+	}
+
+	generated, cmds, err := RenderFS(failingFS{MemFS: m, failOn: filepath.Join("output", "e.txt")}, templates, "output", "world")
+	require.Error(t, err)
+	assert.Equal(t, "disk full", err.Error())
+	assert.Nil(t, generated)
+	assert.Nil(t, cmds)
+
+	require.Len(t, m.Paths(), len(before))
+	for path, content := range before {
+		data, err := m.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+
+	_, err = m.Stat(filepath.Join("output", "replacedpath"))
+	assert.Error(t, err)
+}
+
+func TestRenderKeepsFileMode(t *testing.T) {
+	templates := loadFixturePack(t, "foo")
+	m := NewMemFS()
+	path := filepath.Join("output", "d.txt")
+
+	require.NoError(t, m.WriteFile(path, []byte("// region CODE_REGION(Foo)\n// endregion\n"), 0755))
+
+	_, _, err := RenderFS(m, templates, "output", "world")
+	require.NoError(t, err)
+
+	info, err := m.Stat(path)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0755, info.Mode().Perm())
+}
+
+func TestTransactionRollbackNestedDirectories(t *testing.T) {
+	root := t.TempDir()
+	tx := newTransaction(osFS{})
+
+	require.NoError(t, tx.replace(filepath.Join(root, "a", "b", "c", "f.txt"), []byte("content\n"), 0))
+	require.NoError(t, tx.rollback())
+
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
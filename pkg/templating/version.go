@@ -0,0 +1,52 @@
+package templating
+
+import "runtime/debug"
+
+// modulePath is the path of the module of the codegenerator.
+const modulePath = "code.cestus.io/libs/codegenerator"
+
+// version overrides the version of the codegenerator, see Version. It can be
+// set at build time with:
+//
+//	-ldflags "-X code.cestus.io/libs/codegenerator/pkg/templating.version=1.2.3"
+var version string
+
+// Version returns the version of the codegenerator, checked against the
+// minimum version required by the packs.
+//
+// It is the version of the codegenerator module the binary was built with,
+// unless it was set at build time. It is empty for development builds, which
+// satisfy any minimum version.
+func Version() string {
+	if version != "" {
+		return version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+
+	if !ok {
+		return ""
+	}
+
+	module := &info.Main
+
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			module = dep
+		}
+	}
+
+	if module.Path != modulePath {
+		return ""
+	}
+
+	if module.Replace != nil {
+		module = module.Replace
+	}
+
+	if module.Version == "(devel)" {
+		return ""
+	}
+
+	return module.Version
+}
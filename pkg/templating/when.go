@@ -0,0 +1,686 @@
+package templating
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// The expressions of the `!!when` header follow this grammar:
+//
+//	expression := or
+//	or         := and { "or" and }
+//	and        := not { "and" not }
+//	not        := "not" not | comparison
+//	comparison := operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) operand ]
+//	operand    := "(" expression ")" | field | call | string | number
+//	            | "true" | "false" | "nil"
+//	field      := "." | { "." identifier }
+//	call       := identifier "(" [ expression { "," expression } ] ")"
+//
+// Fields are looked up in the context like in templates and functions are the
+// ones of the Renderer. Values are true as defined by the `if` action of
+// templates.
+
+// whenSyntaxError is returned when an expression can't be parsed.
+type whenSyntaxError struct {
+	// Offset is the position of the error in the expression, in bytes.
+	Offset  int
+	Message string
+}
+
+func (e *whenSyntaxError) Error() string {
+	return e.Message
+}
+
+type whenTokenKind int
+
+const (
+	whenTokenEOF whenTokenKind = iota
+	whenTokenIdentifier
+	whenTokenField
+	whenTokenString
+	whenTokenNumber
+	whenTokenOperator
+	whenTokenLeftParen
+	whenTokenRightParen
+	whenTokenComma
+)
+
+type whenToken struct {
+	kind   whenTokenKind
+	text   string
+	offset int
+}
+
+// lexWhen splits an expression into tokens.
+func lexWhen(source string) ([]whenToken, error) {
+	var tokens []whenToken
+
+	for i := 0; i < len(source); {
+		c := source[i]
+		start := i
+
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, whenToken{whenTokenLeftParen, "(", start})
+			i++
+		case c == ')':
+			tokens = append(tokens, whenToken{whenTokenRightParen, ")", start})
+			i++
+		case c == ',':
+			tokens = append(tokens, whenToken{whenTokenComma, ",", start})
+			i++
+		case c == '=' || c == '!' || c == '<' || c == '>':
+			i++
+			if i < len(source) && source[i] == '=' {
+				i++
+			}
+			if text := source[start:i]; text == "=" || text == "!" {
+				return nil, &whenSyntaxError{start, fmt.Sprintf("unexpected `%s`", text)}
+			}
+			tokens = append(tokens, whenToken{whenTokenOperator, source[start:i], start})
+		case c == '"' || c == '`':
+			i++
+			for i < len(source) && source[i] != c {
+				if c == '"' && source[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(source) {
+				return nil, &whenSyntaxError{start, "unterminated string"}
+			}
+			i++
+			tokens = append(tokens, whenToken{whenTokenString, source[start:i], start})
+		case c == '.':
+			i++
+			for i < len(source) && isWhenIdentifierChar(source[i]) {
+				for i < len(source) && isWhenIdentifierChar(source[i]) {
+					i++
+				}
+				if i < len(source) && source[i] == '.' {
+					i++
+					if i == len(source) || !isWhenIdentifierChar(source[i]) {
+						return nil, &whenSyntaxError{i, "expected a field name"}
+					}
+				}
+			}
+			tokens = append(tokens, whenToken{whenTokenField, source[start:i], start})
+		case c == '-' || c >= '0' && c <= '9':
+			i++
+			for i < len(source) && (isWhenIdentifierChar(source[i]) || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, whenToken{whenTokenNumber, source[start:i], start})
+		case isWhenIdentifierChar(c):
+			for i < len(source) && isWhenIdentifierChar(source[i]) {
+				i++
+			}
+			tokens = append(tokens, whenToken{whenTokenIdentifier, source[start:i], start})
+		default:
+			return nil, &whenSyntaxError{start, fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+
+	return append(tokens, whenToken{whenTokenEOF, "", len(source)}), nil
+}
+
+func isWhenIdentifierChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// whenNode is a node of a parsed expression.
+type whenNode interface {
+	eval(ctx interface{}, funcs template.FuncMap) (interface{}, error)
+}
+
+type whenParser struct {
+	tokens []whenToken
+	pos    int
+}
+
+// parseWhen parses the expression of a `!!when` header.
+func parseWhen(source string) (whenNode, error) {
+	tokens, err := lexWhen(source)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &whenParser{tokens: tokens}
+
+	if p.peek().kind == whenTokenEOF {
+		return nil, &whenSyntaxError{0, "empty expression"}
+	}
+
+	node, err := p.parseOr()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if token := p.peek(); token.kind != whenTokenEOF {
+		return nil, p.unexpected(token)
+	}
+
+	return node, nil
+}
+
+func (p *whenParser) peek() whenToken {
+	return p.tokens[p.pos]
+}
+
+func (p *whenParser) next() whenToken {
+	token := p.tokens[p.pos]
+
+	if token.kind != whenTokenEOF {
+		p.pos++
+	}
+
+	return token
+}
+
+func (p *whenParser) isKeyword(keyword string) bool {
+	token := p.peek()
+
+	return token.kind == whenTokenIdentifier && token.text == keyword
+}
+
+func (p *whenParser) unexpected(token whenToken) error {
+	if token.kind == whenTokenEOF {
+		return &whenSyntaxError{token.offset, "unexpected end of expression"}
+	}
+
+	return &whenSyntaxError{token.offset, fmt.Sprintf("unexpected `%s`", token.text)}
+}
+
+func (p *whenParser) parseOr() (whenNode, error) {
+	left, err := p.parseAnd()
+
+	for err == nil && p.isKeyword("or") {
+		p.next()
+
+		var right whenNode
+		if right, err = p.parseAnd(); err == nil {
+			left = &whenLogical{and: false, left: left, right: right}
+		}
+	}
+
+	return left, err
+}
+
+func (p *whenParser) parseAnd() (whenNode, error) {
+	left, err := p.parseNot()
+
+	for err == nil && p.isKeyword("and") {
+		p.next()
+
+		var right whenNode
+		if right, err = p.parseNot(); err == nil {
+			left = &whenLogical{and: true, left: left, right: right}
+		}
+	}
+
+	return left, err
+}
+
+func (p *whenParser) parseNot() (whenNode, error) {
+	if p.isKeyword("not") {
+		p.next()
+		operand, err := p.parseNot()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &whenNot{operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *whenParser) parseComparison() (whenNode, error) {
+	left, err := p.parseOperand()
+
+	if err != nil || p.peek().kind != whenTokenOperator {
+		return left, err
+	}
+
+	operator := p.next()
+	right, err := p.parseOperand()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &whenComparison{operator: operator.text, left: left, right: right}, nil
+}
+
+func (p *whenParser) parseOperand() (whenNode, error) {
+	token := p.next()
+
+	switch token.kind {
+	case whenTokenLeftParen:
+		node, err := p.parseOr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); closing.kind != whenTokenRightParen {
+			return nil, &whenSyntaxError{closing.offset, "expected `)`"}
+		}
+
+		return node, nil
+	case whenTokenField:
+		var path []string
+		if token.text != "." {
+			path = strings.Split(token.text[1:], ".")
+		}
+		return &whenField{path: path}, nil
+	case whenTokenString:
+		value, err := strconv.Unquote(token.text)
+
+		if err != nil {
+			return nil, &whenSyntaxError{token.offset, fmt.Sprintf("invalid string %s", token.text)}
+		}
+
+		return &whenLiteral{value: value}, nil
+	case whenTokenNumber:
+		if value, err := strconv.ParseInt(token.text, 0, 64); err == nil {
+			return &whenLiteral{value: int(value)}, nil
+		}
+
+		value, err := strconv.ParseFloat(token.text, 64)
+
+		if err != nil {
+			return nil, &whenSyntaxError{token.offset, fmt.Sprintf("invalid number `%s`", token.text)}
+		}
+
+		return &whenLiteral{value: value}, nil
+	case whenTokenIdentifier:
+		switch token.text {
+		case "true":
+			return &whenLiteral{value: true}, nil
+		case "false":
+			return &whenLiteral{value: false}, nil
+		case "nil":
+			return &whenLiteral{value: nil}, nil
+		case "and", "or", "not":
+			return nil, p.unexpected(token)
+		}
+
+		return p.parseCall(token)
+	}
+
+	return nil, p.unexpected(token)
+}
+
+func (p *whenParser) parseCall(name whenToken) (whenNode, error) {
+	if token := p.next(); token.kind != whenTokenLeftParen {
+		return nil, &whenSyntaxError{token.offset, fmt.Sprintf("expected `(` after function `%s`", name.text)}
+	}
+
+	call := &whenCall{name: name.text}
+
+	if p.peek().kind == whenTokenRightParen {
+		p.next()
+		return call, nil
+	}
+
+	for {
+		arg, err := p.parseOr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		call.args = append(call.args, arg)
+
+		switch token := p.next(); token.kind {
+		case whenTokenComma:
+		case whenTokenRightParen:
+			return call, nil
+		default:
+			return nil, &whenSyntaxError{token.offset, "expected `,` or `)`"}
+		}
+	}
+}
+
+// evaluateWhen evaluates the expression of a `!!when` header and reports
+// whether it is true.
+func evaluateWhen(node whenNode, ctx interface{}, funcs template.FuncMap) (bool, error) {
+	value, err := node.eval(ctx, funcs)
+
+	if err != nil {
+		return false, err
+	}
+
+	return isWhenTrue(value), nil
+}
+
+// evaluateWhenExpressions reports whether all the expressions are true.
+func evaluateWhenExpressions(expressions []whenNode, ctx interface{}, funcs template.FuncMap) (bool, error) {
+	for _, expression := range expressions {
+		if ok, err := evaluateWhen(expression, ctx, funcs); err != nil || !ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func isWhenTrue(value interface{}) bool {
+	truth, _ := template.IsTrue(value)
+
+	return truth
+}
+
+type whenLiteral struct {
+	value interface{}
+}
+
+func (n *whenLiteral) eval(interface{}, template.FuncMap) (interface{}, error) {
+	return n.value, nil
+}
+
+type whenLogical struct {
+	and         bool
+	left, right whenNode
+}
+
+func (n *whenLogical) eval(ctx interface{}, funcs template.FuncMap) (interface{}, error) {
+	left, err := evaluateWhen(n.left, ctx, funcs)
+
+	if err != nil || left != n.and {
+		return left, err
+	}
+
+	return evaluateWhen(n.right, ctx, funcs)
+}
+
+type whenNot struct {
+	operand whenNode
+}
+
+func (n *whenNot) eval(ctx interface{}, funcs template.FuncMap) (interface{}, error) {
+	value, err := evaluateWhen(n.operand, ctx, funcs)
+
+	return !value, err
+}
+
+type whenField struct {
+	path []string
+}
+
+func (n *whenField) eval(ctx interface{}, _ template.FuncMap) (interface{}, error) {
+	value := ctx
+
+	for i, name := range n.path {
+		var err error
+
+		if value, err = whenFieldValue(value, name); err != nil {
+			return nil, fmt.Errorf("evaluating `.%s`: %s", strings.Join(n.path[:i+1], "."), err)
+		}
+	}
+
+	return value, nil
+}
+
+// whenFieldValue returns the value of the field, key or method without
+// argument of a value. Missing map keys and fields of nil values are nil.
+func whenFieldValue(value interface{}, name string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	v := reflect.ValueOf(value)
+
+	if method := v.MethodByName(name); method.IsValid() {
+		return callWhenFunc(method, nil)
+	}
+
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("can't index a map of type %s with a field name", v.Type())
+		}
+
+		if item := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())); item.IsValid() {
+			return item.Interface(), nil
+		}
+
+		return nil, nil
+	case reflect.Struct:
+		if field, ok := v.Type().FieldByName(name); ok && field.IsExported() {
+			return v.FieldByIndex(field.Index).Interface(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("can't evaluate field `%s` of type %T", name, value)
+}
+
+type whenCall struct {
+	name string
+	args []whenNode
+}
+
+func (n *whenCall) eval(ctx interface{}, funcs template.FuncMap) (interface{}, error) {
+	fn, ok := funcs[n.name]
+
+	if !ok {
+		return nil, fmt.Errorf("function `%s` not defined", n.name)
+	}
+
+	args := make([]interface{}, len(n.args))
+
+	for i, arg := range n.args {
+		var err error
+
+		if args[i], err = arg.eval(ctx, funcs); err != nil {
+			return nil, err
+		}
+	}
+
+	value, err := callWhenFunc(reflect.ValueOf(fn), args)
+
+	if err != nil {
+		return nil, fmt.Errorf("calling `%s`: %s", n.name, err)
+	}
+
+	return value, nil
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// callWhenFunc calls a template function, converting its arguments to the
+// types of its parameters. A panicking function returns an error, as in
+// text/template.
+func callWhenFunc(fn reflect.Value, args []interface{}) (value interface{}, err error) {
+	fnType := fn.Type()
+
+	if fnType.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%s is not a function", fnType)
+	}
+
+	numIn := fnType.NumIn()
+
+	if fnType.IsVariadic() && len(args) < numIn-1 || !fnType.IsVariadic() && len(args) != numIn {
+		return nil, fmt.Errorf("wrong number of arguments: got %d, want %d", len(args), numIn)
+	}
+
+	in := make([]reflect.Value, len(args))
+
+	for i, arg := range args {
+		paramType := fnType.In(min(i, numIn-1))
+
+		if fnType.IsVariadic() && i >= numIn-1 {
+			paramType = paramType.Elem()
+		}
+
+		value, err := whenArgument(arg, paramType)
+
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %s", i+1, err)
+		}
+
+		in[i] = value
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	out := fn.Call(in)
+
+	switch {
+	case len(out) == 2 && fnType.Out(1) == errorType:
+		if !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+	case len(out) != 1:
+		return nil, fmt.Errorf("functions must return 1 value, or 1 value and an error")
+	}
+
+	return out[0].Interface(), nil
+}
+
+func whenArgument(arg interface{}, paramType reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		switch paramType.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return reflect.Zero(paramType), nil
+		}
+
+		return reflect.Value{}, fmt.Errorf("can't use nil as %s", paramType)
+	}
+
+	value := reflect.ValueOf(arg)
+
+	switch {
+	case value.Type().AssignableTo(paramType):
+		return value, nil
+	case isWhenNumber(value) && isWhenNumber(reflect.Zero(paramType)):
+		return value.Convert(paramType), nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("can't use %T as %s", arg, paramType)
+}
+
+type whenComparison struct {
+	operator    string
+	left, right whenNode
+}
+
+func (n *whenComparison) eval(ctx interface{}, funcs template.FuncMap) (interface{}, error) {
+	left, err := n.left.eval(ctx, funcs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := n.right.eval(ctx, funcs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if n.operator == "==" || n.operator == "!=" {
+		equal := compareWhenEqual(left, right)
+		return equal == (n.operator == "=="), nil
+	}
+
+	cmp, err := compareWhenOrdered(left, right)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.operator {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+func isWhenNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+func compareWhenEqual(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+
+	if cmp, err := compareWhenOrdered(left, right); err == nil {
+		return cmp == 0
+	}
+
+	return reflect.DeepEqual(left, right)
+}
+
+// compareWhenOrdered compares two numbers or two strings.
+func compareWhenOrdered(left, right interface{}) (int, error) {
+	if left != nil && right != nil {
+		l, r := reflect.ValueOf(left), reflect.ValueOf(right)
+
+		switch {
+		case isWhenNumber(l) && isWhenNumber(r):
+			if l.CanInt() && r.CanInt() {
+				return compareWhen(l.Int(), r.Int()), nil
+			}
+
+			if l.CanUint() && r.CanUint() {
+				return compareWhen(l.Uint(), r.Uint()), nil
+			}
+
+			return compareWhen(l.Convert(reflect.TypeOf(0.0)).Float(), r.Convert(reflect.TypeOf(0.0)).Float()), nil
+		case l.Kind() == reflect.String && r.Kind() == reflect.String:
+			return strings.Compare(l.String(), r.String()), nil
+		}
+	}
+
+	return 0, fmt.Errorf("can't compare %T and %T", left, right)
+}
+
+func compareWhen[T int64 | uint64 | float64](left, right T) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,190 @@
+package templating
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateWhen(t *testing.T) {
+	ctx := map[string]interface{}{
+		"Name":     "api",
+		"Replicas": 3,
+		"Ratio":    0.5,
+		"Enabled":  true,
+		"Tags":     []string{},
+		"Service":  map[string]interface{}{"Port": 8080, "Public": false},
+	}
+
+	funcs := defaultFuncMap()
+	funcs["Contains"] = strings.Contains
+	funcs["Fail"] = func() (bool, error) { return false, assert.AnError }
+
+	for expression, expected := range map[string]bool{
+		".Enabled":                         true,
+		"not .Enabled":                     false,
+		"not not .Enabled":                 true,
+		".Tags":                            false,
+		".Missing":                         false,
+		".Missing.Field":                   false,
+		".":                                true,
+		".Enabled and .Service.Public":     false,
+		".Enabled and not .Service.Public": true,
+		"(.Service.Public and .Enabled) or .Name":  true,
+		".Service.Public and (.Enabled or .Name)":  false,
+		".Service.Public and .Enabled or .Name":    true,
+		"not .Service.Public and .Enabled":         true,
+		".Name == \"api\"":                         true,
+		".Name != `api`":                           false,
+		".Name < \"b\"":                            true,
+		".Replicas > 2":                            true,
+		".Replicas >= 3 and .Replicas <= 3":        true,
+		".Replicas == 3.0":                         true,
+		".Ratio < 1":                               true,
+		".Service.Port == 8080":                    true,
+		".Missing == nil":                          true,
+		".Name == nil":                             false,
+		"true and not false":                       true,
+		"Contains(.Name, \"p\")":                   true,
+		"Contains(ToUpper(.Name), \"p\")":          false,
+		"ToGoName(.Name) == \"API\"":               true,
+		"hasKey(., \"Service\") and .Replicas > 1": true,
+		"Fail() or true":                           false,
+		"true or Fail()":                           true,
+		"false and Fail()":                         false,
+	} {
+		node, err := parseWhen(expression)
+		require.NoError(t, err, expression)
+
+		ok, err := evaluateWhen(node, ctx, funcs)
+
+		if expression == "Fail() or true" {
+			assert.EqualError(t, err, "calling `Fail`: "+assert.AnError.Error())
+			continue
+		}
+
+		require.NoError(t, err, expression)
+		assert.Equal(t, expected, ok, expression)
+	}
+
+	for expression, message := range map[string]string{
+		"Unknown(.Name)":            "function `Unknown` not defined",
+		"Contains(.Name)":           "calling `Contains`: wrong number of arguments: got 1, want 2",
+		"Contains(.Replicas, \"\")": "calling `Contains`: argument 1: can't use int as string",
+		".Name < 1":                 "can't compare string and int",
+		".Name.Length":              "evaluating `.Name.Length`: can't evaluate field `Length` of type string",
+		"first(.Replicas)":          "calling `first`: Cannot find first on type int",
+	} {
+		node, err := parseWhen(expression)
+		require.NoError(t, err, expression)
+
+		_, err = evaluateWhen(node, ctx, funcs)
+		assert.EqualError(t, err, message, expression)
+	}
+}
+
+func TestParseWhenErrors(t *testing.T) {
+	for expression, expected := range map[string]whenSyntaxError{
+		"":                  {0, "empty expression"},
+		".A and":            {6, "unexpected end of expression"},
+		"(.A or .B":         {9, "expected `)`"},
+		".A .B":             {3, "unexpected `.B`"},
+		".A = 1":            {3, "unexpected `=`"},
+		".A.":               {3, "expected a field name"},
+		"\"unterminated":    {0, "unterminated string"},
+		"Contains .A":       {9, "expected `(` after function `Contains`"},
+		"Contains(.A .B)":   {12, "expected `,` or `)`"},
+		".A == and":         {6, "unexpected `and`"},
+		"1.2.3 > .A":        {0, "invalid number `1.2.3`"},
+		".A # comment":      {3, "unexpected character '#'"},
+		".A == 1 == 2":      {8, "unexpected `==`"},
+		"not":               {3, "unexpected end of expression"},
+		"Contains(.A, .B,)": {16, "unexpected `)`"},
+	} {
+		_, err := parseWhen(expression)
+		require.Error(t, err, expression)
+
+		var syntaxErr *whenSyntaxError
+		require.ErrorAs(t, err, &syntaxErr, expression)
+		assert.Equal(t, expected, *syntaxErr, expression)
+	}
+}
+
+func TestParseHeadersWhen(t *testing.T) {
+	var header Header
+	_, err := ParseHeaders(bytes.NewBufferString("!!when .A or .B\n!!when not .C\nbody\n"), &header)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		ctx      map[string]interface{}
+		expected bool
+	}{
+		{map[string]interface{}{"A": true}, true},
+		{map[string]interface{}{"B": true}, true},
+		{map[string]interface{}{"A": true, "C": true}, false},
+		{map[string]interface{}{"C": false}, false},
+	} {
+		ok, err := header.When(test.ctx)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, ok, test.ctx)
+
+		ok, keyword, err := header.evaluateIf(test.ctx, defaultFuncMap())
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, ok, test.ctx)
+		if !ok {
+			assert.Equal(t, "when", keyword)
+		}
+	}
+
+	_, err = ParseHeaders(bytes.NewBufferString("!!filename a.txt\n  !!when (.A or .B\n"), &header)
+	assert.EqualError(t, err, "failed to parse `when` header on line 2, column 19: expected `)`")
+
+	_, err = ParseHeaders(bytes.NewBufferString("!!when .A and and .B\n"), &header)
+	assert.EqualError(t, err, "failed to parse `when` header on line 1, column 15: unexpected `and`")
+}
+
+func TestRenderWhen(t *testing.T) {
+	templates := []Template{
+		loadTemplateString(t, "a.txt.template", "!!when (.Kind == \"service\" and .Port > 0) or IsForced(.Kind)\na\n"),
+		loadTemplateString(t, "b.txt.template", "!!foreach .Items\n!!filename {{ .Item }}.txt\n!!when .Index > 0 and .Root.Enabled\n{{ .Item }}\n"),
+	}
+
+	renderer := NewRenderer(WithOutputFS(NewMemFS()), WithFuncs(map[string]interface{}{
+		"IsForced": func(kind string) bool { return kind == "forced" },
+	}))
+
+	for _, test := range []struct {
+		ctx      map[string]interface{}
+		expected []string
+	}{
+		{map[string]interface{}{"Kind": "service", "Port": 80, "Enabled": true, "Items": []string{"x", "y"}}, []string{"a.txt", "y.txt"}},
+		{map[string]interface{}{"Kind": "service", "Port": 0, "Enabled": false, "Items": []string{"x", "y"}}, nil},
+		{map[string]interface{}{"Kind": "forced", "Port": 0, "Enabled": true, "Items": []string{"x"}}, []string{"a.txt"}},
+	} {
+		actions, err := renderer.Plan(templates, "output", test.ctx)
+		require.NoError(t, err)
+
+		var rendered []string
+		for _, action := range actions {
+			if action.Kind != ActionSkipCondition {
+				rendered = append(rendered, filepath.Base(action.Path))
+			}
+		}
+		assert.Equal(t, test.expected, rendered, test.ctx)
+	}
+}
+
+func TestRenderWhenPanic(t *testing.T) {
+	templates := []Template{
+		loadTemplateString(t, "a.txt.template", "!!when first(.Name)\na\n"),
+		loadTemplateString(t, "b.txt", "b\n"),
+	}
+
+	_, err := NewRenderer(WithOutputFS(NewMemFS()), WithParallelism(2)).Plan(templates, "output", map[string]interface{}{"Name": 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "calling `first`: Cannot find first on type int")
+}